@@ -0,0 +1,109 @@
+package birpc
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+)
+
+// State keys consulted by ACL when deciding whether a peer may call a method.
+// They are expected to be set on the connection State once the peer has been
+// identified, for example by an authentication handler.
+const (
+	IdentityKey = "identity" // string identifying the peer
+	RolesKey    = "roles"    // string or []string with the roles of the peer
+)
+
+// ErrPermissionDenied is sent back to the caller when an ACL rejects a request.
+var ErrPermissionDenied = errors.New("birpc: permission denied")
+
+// ACL restricts which peers may call which methods.
+// Rules match method names either exactly or with path.Match style patterns
+// such as "Accounts.*". A method that matches no rule can be called by anyone;
+// a method that matches one or more rules can only be called by peers whose
+// identity or one of whose roles is listed in any of those rules.
+// Internal _goRPC_ methods are never restricted.
+type ACL struct {
+	mu    sync.RWMutex
+	rules []aclRule
+}
+
+type aclRule struct {
+	pattern    string
+	principals map[string]struct{}
+}
+
+// NewACL returns an empty ACL that permits every call.
+func NewACL() *ACL {
+	return &ACL{}
+}
+
+// Allow permits the given principals, roles or identities, to call the
+// methods matching pattern. The principal "*" matches every peer.
+func (a *ACL) Allow(pattern string, principals ...string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic("birpc: malformed ACL pattern " + pattern)
+	}
+	r := aclRule{
+		pattern:    pattern,
+		principals: make(map[string]struct{}, len(principals)),
+	}
+	for _, p := range principals {
+		r.principals[p] = struct{}{}
+	}
+	a.mu.Lock()
+	a.rules = append(a.rules, r)
+	a.mu.Unlock()
+}
+
+// Permitted reports whether the peer described by state may call method.
+// The state may be nil for peers that have not been identified.
+func (a *ACL) Permitted(method string, state *State) bool {
+	if strings.HasPrefix(method, "_goRPC_") {
+		return true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	matched := false
+	var principals []string
+	for _, r := range a.rules {
+		if ok, _ := path.Match(r.pattern, method); !ok {
+			continue
+		}
+		if !matched {
+			matched = true
+			principals = statePrincipals(state)
+		}
+		if _, ok := r.principals["*"]; ok {
+			return true
+		}
+		for _, p := range principals {
+			if _, ok := r.principals[p]; ok {
+				return true
+			}
+		}
+	}
+	return !matched
+}
+
+// statePrincipals returns the identity and roles stored in state.
+func statePrincipals(state *State) (principals []string) {
+	if state == nil {
+		return
+	}
+	if v, ok := state.Get(IdentityKey); ok {
+		if id, ok := v.(string); ok && id != "" {
+			principals = append(principals, id)
+		}
+	}
+	if v, ok := state.Get(RolesKey); ok {
+		switch roles := v.(type) {
+		case string:
+			principals = append(principals, roles)
+		case []string:
+			principals = append(principals, roles...)
+		}
+	}
+	return
+}
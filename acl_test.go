@@ -0,0 +1,79 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestACL(t *testing.T) {
+	acl := NewACL()
+	acl.Allow("Accounts.Delete", "admin")
+	acl.Allow("Reports.*", "auditor", "bob")
+
+	for _, tc := range []struct {
+		method string
+		state  *State
+		want   bool
+	}{
+		{"Accounts.Get", nil, true},
+		{"Accounts.Delete", nil, false},
+		{"Accounts.Delete", stateWith(RolesKey, "user"), false},
+		{"Accounts.Delete", stateWith(RolesKey, []string{"user", "admin"}), true},
+		{"Reports.Monthly", stateWith(IdentityKey, "bob"), true},
+		{"Reports.Monthly", stateWith(IdentityKey, "alice"), false},
+		{"_goRPC_.Cancel", nil, true},
+	} {
+		if got := acl.Permitted(tc.method, tc.state); got != tc.want {
+			t.Errorf("Permitted(%q) = %v, want %v", tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestServerACL(t *testing.T) {
+	acl := NewACL()
+	acl.Allow("delete", "admin")
+
+	srv := NewServer()
+	srv.SetACL(acl)
+	called := false
+	srv.Handle("delete", func(ctx context.Context, id int, reply *bool) error {
+		called = true
+		*reply = true
+		return nil
+	})
+	srv.Handle("get", func(ctx context.Context, id int, reply *int) error {
+		*reply = id
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodecWithState(NewGobCodec(sconn), stateWith(RolesKey, "user"))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	var ok bool
+	err := clt.Call(context.Background(), "delete", 1, &ok)
+	if err == nil || err.Error() != ErrPermissionDenied.Error() {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+	if called {
+		t.Fatal("denied handler was called")
+	}
+
+	// The connection must remain usable after a denied call.
+	var n int
+	if err = clt.Call(context.Background(), "get", 5, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("not expected: %d", n)
+	}
+}
+
+func stateWith(key string, value interface{}) *State {
+	s := NewState()
+	s.Set(key, value)
+	return s
+}
@@ -0,0 +1,48 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+type BenchArgs struct{ A, B int }
+
+func benchmarkCall(b *testing.B, register func(*Server)) {
+	srv := NewServer()
+	register(srv)
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var reply int
+		for pb.Next() {
+			if err := clt.Call(context.Background(), "add", BenchArgs{1, 2}, &reply); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGobReflect(b *testing.B) {
+	benchmarkCall(b, func(srv *Server) {
+		srv.Handle("add", func(ctx context.Context, args *BenchArgs, reply *int) error {
+			*reply = args.A + args.B
+			return nil
+		})
+	})
+}
+
+func BenchmarkGobTyped(b *testing.B) {
+	benchmarkCall(b, func(srv *Server) {
+		HandleFunc(srv, "add", func(ctx context.Context, args *BenchArgs, reply *int) error {
+			*reply = args.A + args.B
+			return nil
+		})
+	})
+}
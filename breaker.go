@@ -0,0 +1,201 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a Breaker for calls it does not let through.
+var ErrCircuitOpen = errors.New("birpc: circuit open")
+
+// BreakerState is the state of the circuit of a method.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all calls through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails calls with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a few trial calls through.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures a Breaker. Zero fields take the defaults.
+type BreakerConfig struct {
+	Window       time.Duration // period failures are counted over, 10s by default
+	MinCalls     int           // calls needed in a window before the circuit opens, 10 by default
+	FailureRatio float64       // ratio of failed calls that opens the circuit, 0.5 by default
+	CoolDown     time.Duration // how long the circuit stays open before trial calls, 5s by default
+	Trials       int           // successful trial calls closing the circuit again, 1 by default
+
+	// IsFailure tells the errors counted as failures.
+	// By default these are connection errors and timeouts, but not the
+	// errors returned by handlers.
+	IsFailure func(err error) bool
+
+	// OnStateChange, if set, is called when the circuit of a method changes state.
+	// It is called with the Breaker locked and must not call it.
+	OnStateChange func(method string, from, to BreakerState)
+}
+
+// Breaker wraps a Caller, failing calls fast with ErrCircuitOpen for the
+// methods whose calls keep failing, instead of piling them up on a peer that
+// is not answering. Each method has its own circuit.
+type Breaker struct {
+	caller Caller
+	cfg    BreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state       BreakerState
+	windowStart time.Time
+	calls       int
+	failures    int
+	openedAt    time.Time
+	trials      int // trial calls in flight
+	successes   int // successful trial calls
+}
+
+// NewBreaker returns a Breaker making the calls with c.
+func NewBreaker(c Caller, cfg BreakerConfig) *Breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinCalls <= 0 {
+		cfg.MinCalls = 10
+	}
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 5 * time.Second
+	}
+	if cfg.Trials <= 0 {
+		cfg.Trials = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool {
+			return IsConnectionError(err) || errors.Is(err, context.DeadlineExceeded)
+		}
+	}
+	return &Breaker{
+		caller:   c,
+		cfg:      cfg,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// State returns the state of the circuit of method.
+func (b *Breaker) State(method string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cb, ok := b.circuits[method]; ok {
+		return cb.state
+	}
+	return BreakerClosed
+}
+
+// Call makes the call unless the circuit of the method is open.
+func (b *Breaker) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	trial, err := b.allow(method)
+	if err != nil {
+		return err
+	}
+	err = b.caller.Call(ctx, method, args, reply)
+	b.done(method, trial, err == nil || !b.cfg.IsFailure(err))
+	return err
+}
+
+// allow reports whether a call to method may go through,
+// and whether it is a trial call.
+func (b *Breaker) allow(method string) (trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb, ok := b.circuits[method]
+	if !ok {
+		cb = &circuit{windowStart: time.Now()}
+		b.circuits[method] = cb
+	}
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < b.cfg.CoolDown {
+			return false, ErrCircuitOpen
+		}
+		b.setState(method, cb, BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if cb.trials+cb.successes >= b.cfg.Trials {
+			return false, ErrCircuitOpen
+		}
+		cb.trials++
+		return true, nil
+	}
+	return false, nil
+}
+
+// done records the outcome of a call allowed through.
+func (b *Breaker) done(method string, trial, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.circuits[method]
+	if trial {
+		if cb.state != BreakerHalfOpen {
+			return
+		}
+		cb.trials--
+		switch {
+		case !ok:
+			b.open(method, cb)
+		case cb.successes+1 >= b.cfg.Trials:
+			b.setState(method, cb, BreakerClosed)
+			cb.windowStart, cb.calls, cb.failures = time.Now(), 0, 0
+		default:
+			cb.successes++
+		}
+		return
+	}
+	if cb.state != BreakerClosed {
+		return // opened by concurrent calls
+	}
+	if now := time.Now(); now.Sub(cb.windowStart) >= b.cfg.Window {
+		cb.windowStart, cb.calls, cb.failures = now, 0, 0
+	}
+	cb.calls++
+	if !ok {
+		cb.failures++
+	}
+	if cb.calls >= b.cfg.MinCalls && float64(cb.failures) >= b.cfg.FailureRatio*float64(cb.calls) {
+		b.open(method, cb)
+	}
+}
+
+func (b *Breaker) open(method string, cb *circuit) {
+	cb.openedAt = time.Now()
+	cb.trials, cb.successes = 0, 0
+	b.setState(method, cb, BreakerOpen)
+}
+
+func (b *Breaker) setState(method string, cb *circuit, state BreakerState) {
+	from := cb.state
+	cb.state = state
+	if b.cfg.OnStateChange != nil && from != state {
+		b.cfg.OnStateChange(method, from, state)
+	}
+}
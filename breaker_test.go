@@ -0,0 +1,67 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type callerFunc func(ctx context.Context, method string, args interface{}, reply interface{}) error
+
+func (f callerFunc) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	return f(ctx, method, args, reply)
+}
+
+func TestBreaker(t *testing.T) {
+	var failing error = ErrShutdown
+	calls := 0
+	var changes []string
+	b := NewBreaker(callerFunc(func(ctx context.Context, method string, args interface{}, reply interface{}) error {
+		calls++
+		return failing
+	}), BreakerConfig{
+		MinCalls: 4,
+		CoolDown: 20 * time.Millisecond,
+		OnStateChange: func(method string, from, to BreakerState) {
+			changes = append(changes, fmt.Sprintf("%s:%s->%s", method, from, to))
+		},
+	})
+
+	for i := 0; i < 4; i++ {
+		b.Call(context.Background(), "app", nil, nil)
+	}
+	if b.State("app") != BreakerOpen {
+		t.Fatal("expected connection errors to open the circuit")
+	}
+	// Handler errors do not count.
+	failing = errors.New("not found")
+	for i := 0; i < 4; i++ {
+		b.Call(context.Background(), "other", nil, nil)
+	}
+	if b.State("other") != BreakerClosed {
+		t.Fatal("handler errors opened the circuit")
+	}
+
+	calls = 0
+	if err := b.Call(context.Background(), "app", nil, nil); err != ErrCircuitOpen || calls != 0 {
+		t.Fatalf("expected fast failure, got: %v after %d calls", err, calls)
+	}
+
+	// A failed trial opens the circuit again, a successful one closes it.
+	time.Sleep(30 * time.Millisecond)
+	failing = context.DeadlineExceeded
+	if err := b.Call(context.Background(), "app", nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected trial call, got: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	failing = nil
+	if err := b.Call(context.Background(), "app", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "[app:closed->open app:open->half-open app:half-open->open app:open->half-open app:half-open->closed]"
+	if got := fmt.Sprint(changes); got != want {
+		t.Fatalf("not expected: %s", got)
+	}
+}
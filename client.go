@@ -5,10 +5,10 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
-	"reflect"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cgrates/birpc/internal/svc"
 )
@@ -18,6 +18,7 @@ import (
 // with a single Client, and a Client may be used by
 // multiple goroutines simultaneously.
 type Client struct {
+	lastActive int64      // UnixNano of the last traffic, accessed atomically and kept first for alignment
 	mutex      sync.Mutex // protects pending, seq, request
 	sending    sync.Mutex
 	request    Request // temp area used in send()
@@ -27,10 +28,31 @@ type Client struct {
 	shutdown   bool
 	server     bool
 	codec      Codec
-	handlers   map[string]*handler
+	handlers   *handlerMap
 	disconnect chan struct{}
-	State      *State // additional information to associate with client
-	blocking   bool   // whether to block request handling
+	closed     chan struct{} // closed by Close or fail, before the read loop ends
+	closeOnce  sync.Once
+	State      *State       // additional information to associate with client
+	blocking   bool         // whether to block request handling
+	acl        *ACL         // restricts incoming requests, nil allows all
+	pool       *WorkerPool  // runs handlers of this connection, nil for a goroutine per request
+	sharedPool *WorkerPool  // shared with other connections of the server, if any
+	limiter    *rateLimiter // limits the rate of incoming requests, if set
+	keyFunc    KeyFunc      // picks the lane of incoming requests in ordered mode
+	lanes      *lanes
+	keepalive  time.Duration            // interval between pings, zero disables them
+	maxMissed  int                      // number of unanswered pings after which the connection is dead
+	idle       time.Duration            // close the connection after this long without traffic
+	failErr    error                    // reason the connection was closed by the client itself
+	streamMu   sync.Mutex               // protects streams and uploads
+	streams    map[uint64]*ServerStream // sending ends of streaming calls being handled, by seq
+	uploads    map[uint64]*upload       // receiving ends of client streaming calls being handled, by seq
+	fallback   FallbackFunc             // handles requests for unknown methods, if set
+	metrics    Metrics                  // receives measurements, if set
+	tracer     Tracer                   // traces calls and handlers, if set
+	logger     *slog.Logger             // logs errors, nil to follow DebugLog
+	accessLog  bool                     // whether to log every handled request
+	access     *slog.Logger             // logs the handled requests, set by Run if accessLog is
 }
 
 // NewClient returns a new Client to handle requests to the
@@ -47,24 +69,72 @@ func NewClientWithCodec(codec Codec) *Client {
 	c := &Client{
 		codec:      codec,
 		pending:    make(map[uint64]*Call),
-		handlers:   make(map[string]*handler),
+		handlers:   newHandlerMap(nil),
 		disconnect: make(chan struct{}),
+		closed:     make(chan struct{}),
 		seq:        1, // 0 means notification.
 	}
-	c.Handle("_goRPC_.Cancel", (&svc.GoRPC{}).Cancel)
+	addInternalHandlers(c.handlers)
 	return c
 }
 
 // SetBlocking puts the client in blocking mode.
 // In blocking mode, received requests are processes synchronously.
 // If you have methods that may take a long time, other subsequent requests may time out.
+// Streaming handlers still run on their own goroutine.
 func (c *Client) SetBlocking(blocking bool) {
 	c.blocking = blocking
 }
 
+// SetACL restricts the methods the other end of the connection may call.
+// Requests denied by acl are answered with ErrPermissionDenied without running the handler.
+func (c *Client) SetACL(acl *ACL) {
+	c.acl = acl
+}
+
+// SetKeyFunc puts the client in ordered mode: requests for which keyFunc
+// returns the same non-empty key are handled in the order they were received,
+// while requests with different keys are handled concurrently.
+// Requests for more than 1024 keys at once, or queueing behind more than 256
+// requests of their key, are answered with ErrServerBusy.
+// Passing nil returns to the default, fully concurrent mode.
+// It has no effect in blocking mode and must be called before Run.
+func (c *Client) SetKeyFunc(keyFunc KeyFunc) {
+	c.keyFunc = keyFunc
+	c.lanes = nil
+	if keyFunc != nil {
+		c.lanes = newLanes()
+	}
+}
+
+// SetWorkerPool runs received requests on pool instead of starting a goroutine for each.
+// Requests that do not fit into the pool are answered with ErrServerBusy.
+// It has no effect in blocking mode.
+func (c *Client) SetWorkerPool(pool *WorkerPool) {
+	c.pool = pool
+}
+
+// SetRateLimits limits the rate of requests the other end of the connection may send.
+// It must be called before Run.
+func (c *Client) SetRateLimits(limits RateLimits) {
+	c.limiter = newRateLimiter(limits)
+}
+
 // Run the client's read loop.
 // You must run this method before calling any methods on the server.
 func (c *Client) Run() {
+	c.initLoggers()
+	c.touch()
+	if c.keepalive > 0 {
+		go c.keepaliveLoop()
+	}
+	if c.idle > 0 {
+		go c.idleLoop()
+	}
+	if c.metrics != nil && !c.server {
+		// Served connections are reported by the server.
+		c.metrics.ConnOpened()
+	}
 	c.readLoop()
 }
 
@@ -75,8 +145,30 @@ func (c *Client) DisconnectNotify() chan struct{} {
 }
 
 // Handle registers the handler function for the given method. If a handler already exists for method, Handle panics.
+// Handlers may be registered while the client is running.
+//
+// On the Client of a connection served by a Server, the handler is only seen by
+// that connection, and overrides the server's handler of the same method.
 func (c *Client) Handle(method string, handlerFunc interface{}) {
-	addHandler(c.handlers, method, handlerFunc)
+	c.handlers.add(method, newHandler(method, handlerFunc))
+}
+
+// Replace registers the handler function for the given method,
+// replacing the existing handler if there is one.
+// Requests already being handled complete with the old handler.
+func (c *Client) Replace(method string, handlerFunc interface{}) {
+	checkReplaceable(method)
+	c.handlers.replace(method, newHandler(method, handlerFunc))
+}
+
+// Unhandle removes the handler of the given method, reporting whether there was one.
+// Requests already being handled complete, later ones fail with "can't find method".
+func (c *Client) Unhandle(method string) bool {
+	return !isInternal(method) && c.handlers.remove(method)
+}
+
+func (c *Client) register(method string, h *handler) {
+	c.handlers.add(method, h)
 }
 
 // readLoop reads messages from codec.
@@ -98,14 +190,17 @@ func (c *Client) readLoop() {
 		}
 
 		if req.Method != "" {
+			if req.Method != pingMethod {
+				c.touch()
+			}
 			// request comes to server
 			if err = c.readRequest(&req, pending); err != nil {
-				debugln("birpc: error reading request:", err.Error())
+				c.log().Debug("birpc: error reading request", "method", req.Method, "seq", req.Seq, "error", err)
 			}
 		} else {
 			// response comes to client
 			if err = c.readResponse(&resp); err != nil {
-				debugln("birpc: error reading response:", err.Error())
+				c.log().Debug("birpc: error reading response", "seq", resp.Seq, "error", err)
 			}
 		}
 	}
@@ -114,7 +209,9 @@ func (c *Client) readLoop() {
 	c.mutex.Lock()
 	c.shutdown = true
 	closing := c.closing
-	if err == io.EOF {
+	if c.failErr != nil {
+		err = c.failErr
+	} else if err == io.EOF {
 		if closing {
 			err = ErrShutdown
 		} else {
@@ -128,7 +225,10 @@ func (c *Client) readLoop() {
 	c.mutex.Unlock()
 	c.sending.Unlock()
 	if err != io.EOF && !closing && !c.server {
-		debugln("birpc: client protocol error:", err)
+		c.log().Debug("birpc: client protocol error", "error", err)
+	}
+	if c.metrics != nil && !c.server {
+		c.metrics.ConnClosed()
 	}
 	close(c.disconnect)
 	if !closing {
@@ -136,83 +236,323 @@ func (c *Client) readLoop() {
 	}
 }
 
-func (c *Client) handleRequest(req Request, method *handler, argv reflect.Value, pending *svc.Pending) {
+// handleRequest runs the handler for req in ctx, started with pending.
+func (c *Client) handleRequest(ctx context.Context, req Request, method *handler, args interface{}, pending *svc.Pending) {
 	// _goRPC_ service calls require internal state.
 	if strings.HasPrefix(req.Method, "_goRPC_") {
-		switch v := argv.Interface().(type) {
+		switch v := args.(type) {
 		case *svc.CancelArgs:
 			v.SetPending(pending)
 		}
 	}
-	ctx := WithClient(pending.Start(req.Seq), c)
+	ctx = WithClient(ctx, c)
+	if req.Seq == 0 {
+		ctx = context.WithValue(ctx, notificationContextKey{}, true)
+	}
+	var endSpan func(error)
+	if c.tracer != nil && !isInternal(req.Method) {
+		ctx, endSpan = c.tracer.StartHandler(ctx, req.Method, req.Header)
+	}
 	defer pending.Cancel(req.Seq)
-	// Invoke the method, providing a new value for the reply.
-	replyv := reflect.New(method.replyType.Elem())
+	var up *upload
+	if method.upload {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		up = c.startUpload(ctx, cancel, req.Seq)
+		defer c.closeUpload(req.Seq)
+	}
+	// Invoke the method, providing a new value for the reply,
+	// or the sending end of the stream for streaming handlers.
+	var reply, body interface{}
+	if method.stream {
+		stream := c.openServerStream(ctx, req)
+		defer c.closeServerStream(req.Seq)
+		reply = stream
+	} else {
+		reply = method.newReply()
+		body = reply
+	}
 
-	returnValues := method.fn.Call([]reflect.Value{reflect.ValueOf(ctx), argv, replyv})
+	var start time.Time
+	measured := c.metrics != nil && !isInternal(req.Method)
+	logged := c.access != nil && !isInternal(req.Method)
+	measuredMethod := req.Method
+	if method.fallback {
+		measuredMethod = FallbackMethod
+	}
+	if measured {
+		c.metrics.InFlight(measuredMethod, 1)
+	}
+	if measured || logged {
+		start = time.Now()
+	}
+	err := method.invoke(ctx, args, reply)
+	if up != nil {
+		if uerr := up.failure(); uerr != nil {
+			err = uerr
+		}
+	}
+	if measured {
+		c.metrics.HandlerDone(measuredMethod, err, time.Since(start))
+		c.metrics.InFlight(measuredMethod, -1)
+	}
+	if logged {
+		c.access.LogAttrs(ctx, slog.LevelInfo, "birpc: request",
+			slog.String("method", req.Method), slog.Uint64("seq", req.Seq),
+			slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+	}
+	if endSpan != nil {
+		endSpan(err)
+	}
 
 	// Do not send response if request is a notification.
 	if req.Seq == 0 {
 		return
 	}
 
-	// The return value for the method is an error.
-	errInter := returnValues[0].Interface()
 	errmsg := ""
-	if errInter != nil {
-		errmsg = errInter.(error).Error()
+	if err != nil {
+		errmsg = err.Error()
 	}
 	resp := &Response{
 		Seq:   req.Seq,
 		Error: errmsg,
 	}
-	if err := c.codec.WriteResponse(resp, replyv.Interface()); err != nil {
-		debugln("birpc: error writing response:", err.Error())
+	if body == nil {
+		// Streams end with an empty response.
+		body = resp
+	}
+	if err := c.codec.WriteResponse(resp, body); err != nil {
+		c.log().Debug("birpc: error writing response", "method", req.Method, "seq", req.Seq, "error", err)
 	}
 }
 
 func (c *Client) readRequest(req *Request, pending *svc.Pending) error {
-	method, ok := c.handlers[req.Method]
+	if req.Error != "" {
+		return c.rejectRequest(req, errors.New(req.Error))
+	}
+	if req.Stream != 0 && req.Stream != StreamOpen {
+		return c.readStreamRequest(req)
+	}
+	method, ok := c.handlers.get(req.Method)
+	fallback := false
+	if !ok && c.fallback != nil && !isInternal(req.Method) {
+		method, ok, fallback = c.fallbackHandler(req.Method), true, true
+	}
 	if !ok {
-		resp := &Response{
-			Seq:   req.Seq,
-			Error: "birpc: can't find method " + req.Method,
+		return c.rejectRequest(req, errors.New("birpc: can't find method "+req.Method))
+	}
+	if c.acl != nil && !c.acl.Permitted(req.Method, c.State) {
+		return c.rejectRequest(req, ErrPermissionDenied)
+	}
+	var wait time.Duration
+	if c.limiter != nil {
+		if wait, ok = c.limiter.reserve(req.Method); !ok {
+			return c.rejectRequest(req, ErrRateLimited)
 		}
-		return c.codec.WriteResponse(resp, resp)
+	}
+
+	if method.upload {
+		// Client streaming handlers receive the items on a channel.
+		if req.Stream != StreamOpen || req.Seq == 0 {
+			return c.rejectRequest(req, errors.New("birpc: method "+req.Method+" must be called with Upload"))
+		}
+		if err := c.codec.ReadRequestBody(nil); err != nil {
+			return err
+		}
+		c.dispatch(*req, method, c.openUpload(req, method.argType), wait, pending)
+		return nil
 	}
 
 	// Decode the argument value.
-	var argv reflect.Value
-	argIsValue := false // if true, need to indirect before calling.
-	if method.argType.Kind() == reflect.Ptr {
-		argv = reflect.New(method.argType.Elem())
-	} else {
-		argv = reflect.New(method.argType)
-		argIsValue = true
+	args := method.newArgs()
+	if err := c.codec.ReadRequestBody(args); err != nil {
+		if !fallback {
+			return err
+		}
+		// The codec cannot pass these params on undecoded, as gob does only
+		// for a []byte. The failed decode has consumed the body, so the
+		// connection can go on with the next message.
+		c.log().Debug("birpc: error decoding fallback params", "method", req.Method, "seq", req.Seq, "error", err)
+		if req.Seq == 0 {
+			return nil
+		}
+		return c.writeError(req.Seq, errors.New("birpc: can't find method "+req.Method+", params cannot be passed to the fallback"))
 	}
-	// argv guaranteed to be a pointer now.
-	if err := c.codec.ReadRequestBody(argv.Interface()); err != nil {
-		return err
+	c.dispatch(*req, method, args, wait, pending)
+	return nil
+}
+
+// dispatch runs the handler for req according to the dispatch mode of the client,
+// after waiting for wait.
+func (c *Client) dispatch(req Request, method *handler, args interface{}, wait time.Duration, pending *svc.Pending) {
+	ctx := pending.Start(req.Seq)
+	run := func() {
+		c.handleRequest(ctx, req, method, args, pending)
+	}
+	switch {
+	case c.blocking:
+		if method.stream || method.upload {
+			// Streaming handlers wait for items or acknowledgements, read by the read loop.
+			go c.delay(ctx, req, wait, pending, run)
+		} else {
+			c.delay(ctx, req, wait, pending, run)
+		}
+	case strings.HasPrefix(req.Method, "_goRPC_"):
+		// Internal calls must not be held back by busy handlers.
+		go run()
+	case c.lanes != nil:
+		if key := c.keyFunc(req.Method, method.args(args)); key != "" {
+			if err := c.lanes.push(key, func() {
+				c.delay(ctx, req, wait, pending, func() {
+					c.execute(req, run, true, pending)
+				})
+			}); err != nil {
+				c.reject(req, err, pending)
+			}
+			return
+		}
+		fallthrough
+	default:
+		if wait > 0 {
+			// Wait before taking a slot of the pools.
+			go c.delay(ctx, req, wait, pending, func() {
+				c.execute(req, run, false, pending)
+			})
+			return
+		}
+		c.execute(req, run, false, pending)
 	}
-	if argIsValue {
-		argv = argv.Elem()
+}
+
+// execute runs the handler on the worker pools of the client,
+// or on a new goroutine if there are none.
+// If wait is true, execute returns once the handler has finished.
+func (c *Client) execute(req Request, run func(), wait bool, pending *svc.Pending) {
+	if c.pool == nil && c.sharedPool == nil {
+		if wait {
+			run()
+		} else {
+			go run()
+		}
+		return
 	}
-	if c.blocking {
-		c.handleRequest(*req, method, argv, pending)
-	} else {
-		go c.handleRequest(*req, method, argv, pending)
+	done := make(chan struct{})
+	c.submit(func() {
+		defer close(done)
+		run()
+	}, func(err error) {
+		defer close(done)
+		c.reject(req, err, pending)
+	})
+	if wait {
+		<-done
 	}
+}
 
-	return nil
+// reject answers a request read but not run with err.
+func (c *Client) reject(req Request, err error, pending *svc.Pending) {
+	pending.Cancel(req.Seq)
+	c.closeUpload(req.Seq)
+	if req.Seq == 0 {
+		c.log().Debug("birpc: dropping notification", "method", req.Method, "error", err)
+		return
+	}
+	if err = c.writeError(req.Seq, err); err != nil {
+		c.log().Debug("birpc: error writing response", "method", req.Method, "seq", req.Seq, "error", err)
+	}
+}
+
+// submit runs task on the worker pools of the client,
+// taking a slot from both the connection pool and the shared pool when both are set.
+// If either of them is full, reject is called instead.
+func (c *Client) submit(task func(), reject func(error)) {
+	pool, shared := c.pool, c.sharedPool
+	if pool == nil {
+		pool, shared = shared, nil
+	}
+	if shared != nil {
+		inner := task
+		task = func() {
+			done := make(chan struct{})
+			if err := shared.Submit(func() {
+				defer close(done)
+				inner()
+			}); err != nil {
+				reject(err)
+				return
+			}
+			<-done
+		}
+	}
+	if err := pool.Submit(task); err != nil {
+		reject(err)
+	}
+}
+
+// delay calls run once req has waited for d, the delay imposed by the rate
+// limits. If req is canceled or the connection closed first, the tokens taken
+// by req are given back and req is answered with the error instead.
+func (c *Client) delay(ctx context.Context, req Request, d time.Duration, pending *svc.Pending, run func()) {
+	if d <= 0 {
+		run()
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	var err error
+	select {
+	case <-t.C:
+		run()
+		return
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-c.closed:
+		err = ErrShutdown
+	case <-c.disconnect:
+		err = ErrShutdown
+	}
+	c.limiter.release(req.Method)
+	c.reject(req, err, pending)
+}
+
+// rejectRequest discards the body of req and answers it with err
+// instead of running the handler. Notifications are dropped silently.
+func (c *Client) rejectRequest(req *Request, err error) error {
+	if rerr := c.codec.ReadRequestBody(nil); rerr != nil {
+		return rerr
+	}
+	if req.Seq == 0 {
+		c.log().Debug("birpc: dropping notification", "method", req.Method, "error", err)
+		return nil
+	}
+	return c.writeError(req.Seq, err)
+}
+
+// writeError answers the request with sequence number seq with err.
+func (c *Client) writeError(seq uint64, err error) error {
+	resp := &Response{
+		Seq:   seq,
+		Error: err.Error(),
+	}
+	return c.codec.WriteResponse(resp, resp)
 }
 
 func (c *Client) readResponse(resp *Response) error {
 	seq := resp.Seq
 	c.mutex.Lock()
 	call := c.pending[seq]
-	delete(c.pending, seq)
+	if resp.Stream == 0 {
+		// Stream frames leave the call pending.
+		delete(c.pending, seq)
+	}
 	c.mutex.Unlock()
 
+	if call != nil && call.Method != pingMethod {
+		c.touch()
+	}
+
 	var err error
 	switch {
 	case call == nil:
@@ -225,6 +565,8 @@ func (c *Client) readResponse(resp *Response) error {
 		if err != nil {
 			err = errors.New("reading error body: " + err.Error())
 		}
+	case resp.Stream != 0:
+		err = c.readStreamResponse(call, resp)
 	case resp.Error != "":
 		// We've got an error response. Give this to the request;
 		// any subsequent requests will get the ReadResponseBody
@@ -255,17 +597,43 @@ func (c *Client) Close() error {
 	}
 	c.closing = true
 	c.mutex.Unlock()
+	c.markClosed()
 	return c.codec.Close()
 }
 
+// isClosed reports whether the connection is closing or closed.
+func (c *Client) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.shutdown || c.closing
+}
+
+// markClosed wakes the handlers waiting for the connection to be closed.
+func (c *Client) markClosed() {
+	c.closeOnce.Do(func() { close(c.closed) })
+}
+
+// report hands the outcome of the call to its metrics and trace span, once.
+func (call *Call) report(err error) {
+	if m := call.metrics; m != nil {
+		call.metrics = nil
+		m.CallDone(call.Method, err, time.Since(call.start))
+	}
+	if end := call.endSpan; end != nil {
+		call.endSpan = nil
+		end(err)
+	}
+}
+
 func (call *Call) done() {
+	call.report(call.Error)
 	select {
 	case call.Done <- call:
 		// ok
 	default:
 		// We don't want to block here.  It is the caller's responsibility to make
 		// sure the channel has enough buffer space. See comment in Go().
-		debugln("birpc: discarding Call reply due to insufficient Done chan capacity")
+		debugLogger.Debug("birpc: discarding Call reply due to insufficient Done chan capacity", "method", call.Method, "seq", call.seq)
 	}
 }
 
@@ -288,12 +656,25 @@ type Call struct {
 	Error  error       // After completion, the error status.
 	Done   chan *Call  // Strobes when call is complete.
 	seq    uint64      // Sequence num used to send. Non-zero when sent.
+	stream *Stream     // receiving end of a streaming call, if any
+	upload *Upload     // sending end of a client streaming call, if any
+
+	ctx     context.Context // context the call is made in
+	metrics Metrics         // receives the outcome of the call, if set
+	start   time.Time       // when the call was sent, for metrics
+	endSpan func(error)     // ends the trace span of the call, if any
 }
 
 func (c *Client) send(call *Call) {
 	c.sending.Lock()
 	defer c.sending.Unlock()
 
+	var header map[string]string
+	if c.tracer != nil && !isInternal(call.Method) {
+		header = make(map[string]string)
+		call.endSpan = c.tracer.StartCall(call.ctx, call.Method, header)
+	}
+
 	// Register this call.
 	c.mutex.Lock()
 	if c.shutdown || c.closing {
@@ -313,11 +694,23 @@ func (c *Client) send(call *Call) {
 	c.seq++
 	call.seq = seq
 	c.pending[seq] = call
+	if c.metrics != nil && !isInternal(call.Method) {
+		call.metrics = c.metrics
+		call.start = time.Now()
+	}
 	c.mutex.Unlock()
+	if call.Method != pingMethod {
+		c.touch()
+	}
 
 	// Encode and send the request.
 	c.request.Seq = seq
 	c.request.Method = call.Method
+	c.request.Stream = 0
+	c.request.Header = header
+	if call.stream != nil || call.upload != nil {
+		c.request.Stream = StreamOpen
+	}
 	err := c.codec.WriteRequest(&c.request, call.Args)
 	if err != nil {
 		c.mutex.Lock()
@@ -336,12 +729,15 @@ func (c *Client) Notify(method string, args interface{}) error {
 	c.sending.Lock()
 	defer c.sending.Unlock()
 
-	if c.shutdown || c.closing {
+	if c.isClosed() {
 		return ErrShutdown
 	}
+	c.touch()
 
 	c.request.Seq = 0
 	c.request.Method = method
+	c.request.Stream = 0
+	c.request.Header = nil
 	return c.codec.WriteRequest(&c.request, args)
 }
 
@@ -350,7 +746,13 @@ func (c *Client) Notify(method string, args interface{}) error {
 // the same Call object.  If done is nil, Go will allocate a new channel.
 // If non-nil, done must be buffered or Go will deliberately crash.
 func (c *Client) Go(method string, args interface{}, reply interface{}, done chan *Call) *Call {
+	return c.goContext(context.Background(), method, args, reply, done)
+}
+
+// goContext is like Go, ctx being the context the call is made in.
+func (c *Client) goContext(ctx context.Context, method string, args interface{}, reply interface{}, done chan *Call) *Call {
 	call := new(Call)
+	call.ctx = ctx
 	call.Method = method
 	call.Args = args
 	call.Reply = reply
@@ -362,7 +764,7 @@ func (c *Client) Go(method string, args interface{}, reply interface{}, done cha
 		// RPCs that will be using that channel.  If the channel
 		// is totally unbuffered, it's best not to run at all.
 		if cap(done) == 0 {
-			log.Panic("birpc: done channel is unbuffered")
+			panic("birpc: done channel is unbuffered")
 		}
 	}
 	call.Done = done
@@ -373,26 +775,36 @@ func (c *Client) Go(method string, args interface{}, reply interface{}, done cha
 // Call invokes the named function, waits for it to complete, and returns its error status.
 func (client *Client) Call(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
 	ch := make(chan *Call, 2) // 2 for this call and cancel
-	call := client.Go(serviceMethod, args, reply, ch)
+	call := client.goContext(ctx, serviceMethod, args, reply, ch)
 	select {
 	case <-call.Done:
 		return call.Error
 	case <-ctx.Done():
-		// Cancel the pending request on the client
-		client.mutex.Lock()
-		seq := call.seq
-		_, ok := client.pending[seq]
-		delete(client.pending, seq)
-		if seq == 0 {
-			// hasn't been sent yet, non-zero will prevent send
-			call.seq = 1
-		}
-		client.mutex.Unlock()
-
-		// Cancel running request on the server
-		if seq != 0 && ok {
-			client.Go("_goRPC_.Cancel", &svc.CancelArgs{Seq: seq}, nil, ch)
-		}
+		client.cancel(call, ctx.Err())
 		return ctx.Err()
 	}
 }
+
+// cancel stops waiting for call and asks the other end to cancel the
+// running request, err telling why. The answer to the cancel request is
+// delivered to call.Done, which needs room for it.
+func (c *Client) cancel(call *Call, err error) {
+	// Cancel the pending request on the client
+	c.mutex.Lock()
+	seq := call.seq
+	_, ok := c.pending[seq]
+	delete(c.pending, seq)
+	if seq == 0 {
+		// hasn't been sent yet, non-zero will prevent send
+		call.seq = 1
+	}
+	c.mutex.Unlock()
+
+	if ok {
+		call.report(err)
+	}
+	// Cancel running request on the server
+	if seq != 0 && ok {
+		c.Go("_goRPC_.Cancel", &svc.CancelArgs{Seq: seq}, nil, call.Done)
+	}
+}
@@ -0,0 +1,278 @@
+// Command birpc-gen generates typed client stubs and handler registrations
+// for a service described by a Go interface.
+//
+// Every method of the interface must have the signature
+//
+//	Method(ctx context.Context, args Args, reply *Reply) error
+//
+// For an interface named Calc, birpc-gen writes a CalcClient type whose
+// methods call birpc.Client.Call with the method names "Calc.Method", and a
+// RegisterCalc function that registers an implementation of Calc with a
+// birpc.Server or birpc.Client under the same names.
+//
+// It is meant to be run by go generate:
+//
+//	//go:generate birpc-gen -type Calc
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("birpc-gen: ")
+	typeName := flag.String("type", "", "name of the service interface (required)")
+	service := flag.String("service", "", "service name prefixed to the method names (default: the interface name)")
+	output := flag.String("o", "", "output file (default: <type>_birpc.go in the package directory)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: birpc-gen -type Name [flags] [directory]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeName == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *service == "" {
+		*service = *typeName
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(*typeName)+"_birpc.go")
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			src, err := generate(fset, file, *typeName, *service)
+			if errors.Is(err, errNotFound) {
+				continue
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err = os.WriteFile(*output, src, 0644); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	log.Fatalf("interface %s not found in %s", *typeName, dir)
+}
+
+var errNotFound = errors.New("interface not found")
+
+// method describes a method of the service interface.
+type method struct {
+	Name  string
+	Args  string // argument type expression
+	Reply string // reply type expression, without the pointer
+}
+
+// generate returns the source of the client stub and registration function
+// for the interface typeName declared in file.
+func generate(fset *token.FileSet, file *ast.File, typeName, service string) ([]byte, error) {
+	iface := findInterface(file, typeName)
+	if iface == nil {
+		return nil, errNotFound
+	}
+	imports := make(map[string]string) // name -> import path
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := packageName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+	used := make(map[string]bool)
+	expr := func(e ast.Expr) string {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok {
+					used[id.Name] = true
+				}
+			}
+			return true
+		})
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, e)
+		return buf.String()
+	}
+
+	var methods []method
+	for _, field := range iface.Methods.List {
+		if len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", fset.Position(field.Pos()))
+		}
+		fn := field.Type.(*ast.FuncType)
+		params := flatten(fn.Params)
+		if len(params) != 3 || !isSelector(params[0], "context", "Context") {
+			return nil, fmt.Errorf("%s: method %s must take a context.Context, the arguments and a pointer to the reply",
+				fset.Position(field.Pos()), field.Names[0].Name)
+		}
+		reply, ok := params[2].(*ast.StarExpr)
+		if !ok {
+			return nil, fmt.Errorf("%s: reply of method %s must be a pointer", fset.Position(field.Pos()), field.Names[0].Name)
+		}
+		if _, ok := params[1].(*ast.ChanType); ok || isSelector(reply.X, "birpc", "ServerStream") {
+			return nil, fmt.Errorf("%s: streaming method %s is not supported", fset.Position(field.Pos()), field.Names[0].Name)
+		}
+		results := flatten(fn.Results)
+		if len(results) != 1 || !isIdent(results[0], "error") {
+			return nil, fmt.Errorf("%s: method %s must return error", fset.Position(field.Pos()), field.Names[0].Name)
+		}
+		methods = append(methods, method{
+			Name:  field.Names[0].Name,
+			Args:  expr(params[1]),
+			Reply: expr(reply.X),
+		})
+	}
+	delete(used, "context")
+	delete(used, "birpc")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by birpc-gen -type %s; DO NOT EDIT.\n\n", typeName)
+	fmt.Fprintf(&b, "package %s\n\n", file.Name.Name)
+	std := []string{"\"context\""}
+	others := []string{"\"github.com/cgrates/birpc\""}
+	for name := range used {
+		importPath, ok := imports[name]
+		if !ok {
+			continue // not a package
+		}
+		spec := strconv.Quote(importPath)
+		if packageName(importPath) != name {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	fmt.Fprintf(&b, "import (\n\t%s\n\n\t%s\n)\n\n", strings.Join(std, "\n\t"), strings.Join(others, "\n\t"))
+
+	fmt.Fprintf(&b, "// Method names of the %s service.\nconst (\n", service)
+	for _, m := range methods {
+		fmt.Fprintf(&b, "\t%s%sMethod = %q\n", typeName, m.Name, service+"."+m.Name)
+	}
+	b.WriteString(")\n\n")
+
+	client := typeName + "Client"
+	fmt.Fprintf(&b, "// %s calls the methods of the %s service over a birpc.Client.\n", client, service)
+	fmt.Fprintf(&b, "type %s struct {\n\tc *birpc.Client\n}\n\n", client)
+	fmt.Fprintf(&b, "var _ %s = (*%s)(nil)\n\n", typeName, client)
+	fmt.Fprintf(&b, "// New%s returns a %s calling the methods over c.\n", client, client)
+	fmt.Fprintf(&b, "func New%s(c *birpc.Client) *%s {\n\treturn &%s{c: c}\n}\n\n", client, client, client)
+	for _, m := range methods {
+		fmt.Fprintf(&b, "// %s calls %s.%s.\n", m.Name, service, m.Name)
+		fmt.Fprintf(&b, "func (c *%s) %s(ctx context.Context, args %s, reply *%s) error {\n", client, m.Name, m.Args, m.Reply)
+		fmt.Fprintf(&b, "\treturn c.c.Call(ctx, %s%sMethod, args, reply)\n}\n\n", typeName, m.Name)
+	}
+
+	fmt.Fprintf(&b, "// Register%s registers the methods of impl with r, a *birpc.Server or a *birpc.Client.\n", typeName)
+	fmt.Fprintf(&b, "func Register%s(r birpc.Registry, impl %s) {\n", typeName, typeName)
+	for _, m := range methods {
+		fmt.Fprintf(&b, "\tbirpc.HandleFunc(r, %s%sMethod, impl.%s)\n", typeName, m.Name, m.Name)
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// findInterface returns the interface type declared as name in file.
+func findInterface(file *ast.File, name string) *ast.InterfaceType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != name {
+				continue
+			}
+			if iface, ok := ts.Type.(*ast.InterfaceType); ok {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+// flatten returns the type of every parameter in fields,
+// repeating it for parameters declared together.
+func flatten(fields *ast.FieldList) (types []ast.Expr) {
+	if fields == nil {
+		return nil
+	}
+	for _, f := range fields.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, f.Type)
+		}
+	}
+	return types
+}
+
+// packageName returns the name assumed for the package imported as importPath:
+// its last element, leaving out a major version suffix like /v2 and a go-
+// prefix, up to the first character that cannot be in a name. Packages named
+// otherwise must be imported with a name in the service file.
+func packageName(importPath string) string {
+	name := path.Base(importPath)
+	if major := strings.TrimPrefix(name, "v"); major != name {
+		if _, err := strconv.Atoi(major); err == nil && path.Dir(importPath) != "." {
+			name = path.Base(path.Dir(importPath))
+		}
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func isSelector(e ast.Expr, pkg, name string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == name && isIdent(sel.X, pkg)
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
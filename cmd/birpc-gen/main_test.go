@@ -0,0 +1,108 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// services are the interfaces in testdata, generated into <file>_birpc.go.golden.
+var services = []struct {
+	file, typeName string
+}{
+	{"calc", "Calc"},
+	{"seed", "Seeder"}, // imports packages with a major version suffix
+}
+
+func TestGenerate(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, s := range services {
+		file, err := parser.ParseFile(fset, "testdata/"+s.file+".go", nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := generate(fset, file, s.typeName, s.typeName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile("testdata/" + s.file + "_birpc.go.golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("generated code of %s differs from the golden file:\n%s", s.typeName, got)
+		}
+	}
+
+	file, err := parser.ParseFile(fset, "bad.go", `package bad
+type Bad interface {
+	Add(args int, reply *int) error
+}`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = generate(fset, file, "Missing", "Missing"); err != errNotFound {
+		t.Fatalf("expected errNotFound, got: %v", err)
+	}
+	if _, err = generate(fset, file, "Bad", "Bad"); err == nil || !strings.Contains(err.Error(), "context.Context") {
+		t.Fatalf("expected signature error, got: %v", err)
+	}
+}
+
+// TestGeneratedCompiles type checks the generated code with the services it was generated for.
+func TestGeneratedCompiles(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	// Inside the module, for the birpc import to resolve.
+	dir, err := os.MkdirTemp(".", "_calc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fset := token.NewFileSet()
+	for _, s := range services {
+		service, err := os.ReadFile("testdata/" + s.file + ".go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, s.file+".go", service, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := generate(fset, file, s.typeName, s.typeName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, s.file+".go"), service, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, s.file+"_birpc.go"), src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Read-only, so that the imports of the services do not change go.mod.
+	if out, err := exec.Command(goTool, "vet", "-mod=readonly", "./"+dir).CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, out)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	for path, want := range map[string]string{
+		"time":                        "time",
+		"math/rand/v2":                "rand",
+		"github.com/gofrs/uuid/v5":    "uuid",
+		"gopkg.in/yaml.v3":            "yaml",
+		"github.com/mattn/go-sqlite3": "sqlite3",
+		"v2":                          "v2",
+	} {
+		if got := packageName(path); got != want {
+			t.Errorf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package calc
+
+import (
+	"context"
+	"time"
+)
+
+type Args struct{ A, B int }
+
+type Calc interface {
+	Add(ctx context.Context, args Args, reply *int) error
+	Div(ctx context.Context, args *Args, reply *float64) error
+	Sleep(ctx context.Context, d time.Duration, reply *string) error
+}
@@ -0,0 +1,12 @@
+package calc
+
+import (
+	"context"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+type Seeder interface {
+	Hash(ctx context.Context, args string, reply *xxhash.Digest) error
+	Sum(ctx context.Context, args *xxhash.Digest, reply *uint64) error
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// gobValue decodes JSON into a value gob can send to a handler expecting
+// the matching Go type. Integers become int64, other numbers float64, arrays
+// slices and objects structs, with the keys capitalized as field names.
+func gobValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("extra data after the JSON value")
+	}
+	rv, err := toGob(v)
+	if err != nil {
+		return nil, err
+	}
+	return rv.Interface(), nil
+}
+
+func toGob(v interface{}) (reflect.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return reflect.Value{}, errors.New("null cannot be sent with gob")
+	case bool, string:
+		return reflect.ValueOf(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return reflect.ValueOf(i), nil
+		}
+		f, err := v.Float64()
+		return reflect.ValueOf(f), err
+	case []interface{}:
+		if len(v) == 0 {
+			return reflect.Value{}, errors.New("cannot tell the element type of an empty array")
+		}
+		var elems []reflect.Value
+		for i, e := range v {
+			ev, err := toGob(e)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if i > 0 && ev.Type() != elems[0].Type() {
+				return reflect.Value{}, fmt.Errorf("array mixes %s and %s elements", elems[0].Type(), ev.Type())
+			}
+			elems = append(elems, ev)
+		}
+		s := reflect.MakeSlice(reflect.SliceOf(elems[0].Type()), 0, len(elems))
+		return reflect.Append(s, elems...), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]reflect.StructField, len(keys))
+		values := make([]reflect.Value, len(keys))
+		names := make(map[string]string, len(keys)) // keys by field name
+		for i, k := range keys {
+			name := exported(k)
+			if !isIdentifier(name) {
+				return reflect.Value{}, fmt.Errorf("key %q is not a valid field name", k)
+			}
+			if prev, ok := names[name]; ok {
+				return reflect.Value{}, fmt.Errorf("keys %q and %q are both field %s", prev, k, name)
+			}
+			names[name] = k
+			fv, err := toGob(v[k])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			fields[i] = reflect.StructField{Name: name, Type: fv.Type()}
+			values[i] = fv
+		}
+		st := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, fv := range values {
+			st.Field(i).Set(fv)
+		}
+		return st, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unexpected JSON value %v", v)
+}
+
+// exported returns name with its first letter capitalized.
+func exported(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsLetter(r)
+}
@@ -0,0 +1,281 @@
+// Command birpc calls the methods of birpc servers from the command line.
+//
+// Usage:
+//
+//	birpc [flags] call method [args]
+//	birpc [flags] notify method [args]
+//	birpc [flags] listen
+//
+// The call command calls method with args, given as JSON, and prints the
+// reply as JSON. The notify command sends method as a notification. Both
+// connect to the address given by -addr over -net, which is tcp, unix, or
+// stdio to talk over the standard input and output, in which case replies
+// are printed to the standard error.
+//
+// The listen command accepts connections on -addr instead, or serves the
+// standard input and output, and answers every call with its params.
+//
+// With the json codec, calls the other end makes on the connection, while
+// waiting for a reply or listening, are logged to the standard error and
+// answered with their params. An args array is sent as the params array,
+// anything else as its only element.
+//
+// With the gob codec, args are sent with types built from their JSON: numbers
+// become int64 or float64 and objects become structs, their keys capitalized
+// to be the field names. Since gob has no null, args are required. Replies
+// are discarded unless -reply gives a JSON sample of them, like {"Sum":0}.
+// Gob cannot pass on values of unknown types, so calls from the other end are
+// answered with an error, and listen needs the json codec.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cgrates/birpc"
+	"github.com/cgrates/birpc/jsonrpc"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("birpc: ")
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+// cli holds the settings of a run.
+type cli struct {
+	network string
+	addr    string
+	codec   string
+	timeout time.Duration
+	reply   string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	flags := flag.NewFlagSet("birpc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.network, "net", "tcp", "transport: tcp, unix or stdio")
+	flags.StringVar(&c.addr, "addr", "localhost:2012", "address to connect to or listen on, a path for unix")
+	flags.StringVar(&c.codec, "codec", "json", "codec: json or gob")
+	flags.DurationVar(&c.timeout, "timeout", 30*time.Second, "how long to wait for a reply, 0 for no limit")
+	flags.StringVar(&c.reply, "reply", "", "JSON sample of the reply, needed to decode it with gob")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: birpc [flags] call method [args]")
+		fmt.Fprintln(stderr, "       birpc [flags] notify method [args]")
+		fmt.Fprintln(stderr, "       birpc [flags] listen")
+		fmt.Fprintln(stderr, "args are JSON, required with gob; listen needs the json codec")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return flag.ErrHelp // reported by flags
+	}
+	switch c.network {
+	case "tcp", "unix", "stdio":
+	default:
+		return fmt.Errorf("unknown transport %q", c.network)
+	}
+	switch c.codec {
+	case "json", "gob":
+	default:
+		return fmt.Errorf("unknown codec %q", c.codec)
+	}
+
+	cmd := flags.Args()
+	switch {
+	case len(cmd) == 1 && cmd[0] == "listen":
+		return c.listen()
+	case len(cmd) == 2 || len(cmd) == 3:
+		params := ""
+		if len(cmd) == 3 {
+			params = cmd[2]
+		}
+		switch cmd[0] {
+		case "call":
+			return c.call(cmd[1], params)
+		case "notify":
+			return c.notify(cmd[1], params)
+		}
+	}
+	flags.Usage()
+	return flag.ErrHelp
+}
+
+// stdio is the connection over the standard input and output.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error {
+	return nil
+}
+
+func (c *cli) dial() (*birpc.Client, error) {
+	var conn io.ReadWriteCloser = stdio{c.stdin, c.stdout}
+	if c.network != "stdio" {
+		var err error
+		if conn, err = net.Dial(c.network, c.addr); err != nil {
+			return nil, err
+		}
+	}
+	clt := birpc.NewClientWithCodec(c.newCodec(conn))
+	if c.codec == "json" {
+		clt.SetFallback(c.echo)
+	}
+	go clt.Run()
+	return clt, nil
+}
+
+func (c *cli) newCodec(conn io.ReadWriteCloser) birpc.Codec {
+	if c.codec == "gob" {
+		return birpc.NewGobCodec(conn)
+	}
+	return jsonrpc.NewJSONCodec(conn)
+}
+
+// args returns the value to send for the JSON params, null if there are none.
+func (c *cli) args(params string) (interface{}, error) {
+	if c.codec == "gob" {
+		if params == "" {
+			return nil, errors.New("args are required with the gob codec")
+		}
+		return gobValue([]byte(params))
+	}
+	if params == "" {
+		params = "null"
+	}
+	if !json.Valid([]byte(params)) {
+		return nil, errors.New("args are not valid JSON")
+	}
+	params = strings.TrimSpace(params)
+	if !strings.HasPrefix(params, "[") {
+		params = "[" + params + "]"
+	}
+	return birpc.RawMessage(params), nil
+}
+
+func (c *cli) call(method, params string) error {
+	args, err := c.args(params)
+	if err != nil {
+		return err
+	}
+	var reply interface{}
+	switch {
+	case c.codec == "json":
+		reply = new(birpc.RawMessage)
+	case c.reply != "":
+		sample, err := gobValue([]byte(c.reply))
+		if err != nil {
+			return fmt.Errorf("reply sample: %w", err)
+		}
+		reply = reflect.New(reflect.TypeOf(sample)).Interface()
+	}
+	clt, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer clt.Close()
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if err = clt.Call(ctx, method, args, reply); err != nil {
+		return err
+	}
+	if reply == nil {
+		return nil
+	}
+	out, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.output(), "%s\n", out)
+	return err
+}
+
+// output returns where replies are printed, the standard error if the
+// standard output carries the connection.
+func (c *cli) output() io.Writer {
+	if c.network == "stdio" {
+		return c.stderr
+	}
+	return c.stdout
+}
+
+func (c *cli) notify(method, params string) error {
+	args, err := c.args(params)
+	if err != nil {
+		return err
+	}
+	clt, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer clt.Close()
+	return clt.Notify(method, args)
+}
+
+// listen serves connections until the listener fails,
+// or the standard input until it is closed.
+func (c *cli) listen() error {
+	if c.codec != "json" {
+		return errors.New("listen needs the json codec")
+	}
+	srv := birpc.NewServer()
+	srv.SetFallback(c.echo)
+	if c.network == "stdio" {
+		srv.ServeCodec(c.newCodec(stdio{c.stdin, c.stdout}))
+		return nil
+	}
+	lis, err := net.Listen(c.network, c.addr)
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeCodec(c.newCodec(conn))
+	}
+}
+
+// echo logs the calls from the other end and answers them with their params,
+// the only one for a single param.
+func (c *cli) echo(ctx context.Context, method string, params birpc.RawMessage, reply *birpc.RawMessage) error {
+	kind := "call"
+	if birpc.IsNotification(ctx) {
+		kind = "notification"
+	}
+	fmt.Fprintf(c.stderr, "%s %s %s\n", kind, method, params)
+	var list []json.RawMessage
+	if err := json.Unmarshal(params, &list); err == nil && len(list) == 1 {
+		*reply = birpc.RawMessage(list[0])
+		return nil
+	}
+	*reply = params
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cgrates/birpc"
+	"github.com/cgrates/birpc/jsonrpc"
+)
+
+type AddArgs struct {
+	A, B int
+}
+
+type AddReply struct {
+	Sum int
+}
+
+// serve starts a server with the given codec and returns its address.
+func serve(t *testing.T, codec string) string {
+	srv := birpc.NewServer()
+	srv.Handle("Calc.Add", func(ctx context.Context, args AddArgs, reply *AddReply) error {
+		reply.Sum = args.A + args.B
+		return nil
+	})
+	srv.Handle("Calc.Greet", func(ctx context.Context, name string, reply *string) error {
+		// Ask the caller to repeat the greeting.
+		return birpc.ClientValueFromContext(ctx).Call(ctx, "Echo", "hello "+name, reply)
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if codec == "gob" {
+				go srv.ServeConn(conn)
+			} else {
+				go srv.ServeCodec(jsonrpc.NewJSONCodec(conn))
+			}
+		}
+	}()
+	return lis.Addr().String()
+}
+
+func TestCall(t *testing.T) {
+	for _, tc := range []struct {
+		codec string
+		args  []string
+		want  string
+	}{
+		{"json", []string{"call", "Calc.Add", `{"A":1,"B":2}`}, `{"Sum":3}`},
+		{"json", []string{"call", "Calc.Greet", `"bob"`}, `"hello bob"`},
+		{"gob", []string{"-reply", `{"Sum":0}`, "call", "Calc.Add", `{"a":1,"b":2}`}, `{"Sum":3}`},
+		{"gob", []string{"call", "Calc.Add", `{"A":1,"B":2}`}, ``},
+	} {
+		addr := serve(t, tc.codec)
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-addr", addr, "-codec", tc.codec}, tc.args...)
+		if err := run(args, nil, &stdout, &stderr); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != tc.want {
+			t.Fatalf("%v: not expected: %s", tc.args, got)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-addr", serve(t, "json"), "call", "Calc.Missing"}, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("not expected: %v", err)
+	}
+	if err = run([]string{"call"}, nil, &stdout, &stderr); err == nil || !strings.Contains(stderr.String(), "usage") {
+		t.Fatalf("expected usage, got: %v", err)
+	}
+}
+
+func TestGobValue(t *testing.T) {
+	v, err := gobValue([]byte(`{"name":"bob","tags":["a","b"],"point":{"x":1.5,"y":2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type point struct {
+		X float64
+		Y int64
+	}
+	want := struct {
+		Name  string
+		Point point
+		Tags  []string
+	}{"bob", point{1.5, 2}, []string{"a", "b"}}
+	if got := fmt.Sprintf("%+v", v); got != fmt.Sprintf("%+v", want) {
+		t.Fatalf("not expected: %s", got)
+	}
+	for _, bad := range []string{`null`, `[]`, `[1,"a"]`, `{"a b":1}`, `{"a":1,"A":2}`} {
+		if _, err := gobValue([]byte(bad)); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+// pipeConn is one end of a connection made of two pipes.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// pipes returns the ends of a connection, for the command over stdio and for the test.
+func pipes(t *testing.T) (stdin io.Reader, stdout io.Writer, peer io.ReadWriteCloser) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	t.Cleanup(func() {
+		inW.Close()
+		outW.Close()
+	})
+	return inR, outW, pipeConn{outR, inW}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNotify(t *testing.T) {
+	logged := make(chan string, 1)
+	srv := birpc.NewServer()
+	srv.Handle("Log", func(ctx context.Context, msg string, _ *bool) error {
+		logged <- msg
+		return nil
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeCodec(jsonrpc.NewJSONCodec(conn))
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-addr", lis.Addr().String(), "notify", "Log", `"hello"`}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-logged:
+		if msg != "hello" {
+			t.Fatalf("not expected: %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification not received")
+	}
+	if err := run([]string{"-codec", "gob", "notify", "Log"}, nil, &stdout, &stderr); err == nil {
+		t.Fatal("expected error for a gob notification without args")
+	}
+}
+
+func TestUnix(t *testing.T) {
+	srv := birpc.NewServer()
+	srv.Handle("Calc.Add", func(ctx context.Context, args AddArgs, reply *AddReply) error {
+		reply.Sum = args.A + args.B
+		return nil
+	})
+	path := filepath.Join(t.TempDir(), "birpc.sock")
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets not available:", err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn)
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-net", "unix", "-addr", path, "-codec", "gob", "-reply", `{"Sum":0}`, "call", "Calc.Add", `{"A":2,"B":3}`}
+	if err := run(args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != `{"Sum":5}` {
+		t.Fatalf("not expected: %s", got)
+	}
+}
+
+func TestStdioCall(t *testing.T) {
+	srv := birpc.NewServer()
+	srv.Handle("Calc.Add", func(ctx context.Context, args AddArgs, reply *AddReply) error {
+		reply.Sum = args.A + args.B
+		return nil
+	})
+	stdin, stdout, peer := pipes(t)
+	go srv.ServeCodec(jsonrpc.NewJSONCodec(peer))
+
+	var stderr syncBuffer
+	if err := run([]string{"-net", "stdio", "call", "Calc.Add", `{"A":1,"B":1}`}, stdin, stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	// The reply goes to the standard error, the standard output carries the connection.
+	if got := strings.TrimSpace(stderr.String()); got != `{"Sum":2}` {
+		t.Fatalf("not expected: %s", got)
+	}
+}
+
+func TestListen(t *testing.T) {
+	stdin, stdout, peer := pipes(t)
+	var stderr syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- run([]string{"-net", "stdio", "listen"}, stdin, stdout, &stderr)
+	}()
+
+	clt := birpc.NewClientWithCodec(jsonrpc.NewJSONCodec(peer))
+	go clt.Run()
+	var reply birpc.RawMessage
+	if err := clt.Call(context.Background(), "Any.Method", map[string]int{"x": 1}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != `{"x":1}` {
+		t.Fatalf("not expected: %s", reply)
+	}
+	if err := clt.Call(context.Background(), "Any.Pair", []int{1, 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != `[1,2]` {
+		t.Fatalf("not expected: %s", reply)
+	}
+	clt.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listen did not return at the end of its input")
+	}
+	want := "call Any.Method [{\"x\":1}]\ncall Any.Pair [1,2]\n"
+	if got := stderr.String(); got != want {
+		t.Fatalf("not expected log: %q", got)
+	}
+
+	if err := run([]string{"-net", "stdio", "-codec", "gob", "listen"}, stdin, stdout, &stderr); err == nil {
+		t.Fatal("expected error listening with gob")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/gob"
 	"io"
+	"net"
 	"sync"
 )
 
@@ -14,6 +15,8 @@ import (
 // ReadResponseBody is called right after ReadHeader.
 // ReadRequestBody and ReadResponseBody may be called with a nil
 // argument to force the body to be read and then discarded.
+// A Codec may also have a RemoteAddr() net.Addr method, reporting the
+// address of the peer, which then appears in the logs of the connection.
 type Codec interface {
 	// ReadHeader must read a message and populate either the request
 	// or the response by inspecting the incoming message.
@@ -39,14 +42,40 @@ type Codec interface {
 type Request struct {
 	Seq    uint64 // sequence number chosen by client
 	Method string
+	Stream StreamFrame       // non-zero for messages of a streaming call
+	Header map[string]string // metadata of the call, such as the trace context, may be nil
+	Error  string            // set by the codec to answer the request with an error instead of running it
 }
 
 // Response is a header written before every RPC return.
 type Response struct {
-	Seq   uint64 // echoes that of the request
-	Error string // error, if any.
+	Seq    uint64      // echoes that of the request
+	Error  string      // error, if any.
+	Stream StreamFrame // non-zero for messages of a streaming call
 }
 
+// StreamFrame marks the messages that make up a streaming call.
+// Besides opening the call, frames travel under the sequence number of the
+// request that opened it: items and acknowledgements from the handler are sent
+// as responses, the ones from the caller as requests.
+type StreamFrame uint8
+
+const (
+	// StreamOpen marks the request that opens a streaming call.
+	StreamOpen StreamFrame = iota + 1
+
+	// StreamData carries one item of the stream as its body.
+	StreamData
+
+	// StreamAck carries, as a uint64 body, the number of items the
+	// receiver has consumed, allowing the sender to send as many more.
+	StreamAck
+
+	// StreamEnd tells the handler of a client streaming call that the
+	// caller has sent all items. Its body is ignored.
+	StreamEnd
+)
+
 type gobCodec struct {
 	rwc    io.ReadWriteCloser
 	dec    *gob.Decoder
@@ -59,6 +88,8 @@ type message struct {
 	Seq    uint64
 	Method string
 	Error  string
+	Stream StreamFrame
+	Header map[string]string
 }
 
 // NewGobCodec returns a new birpc.Codec using gob encoding/decoding on conn.
@@ -81,9 +112,12 @@ func (c *gobCodec) ReadHeader(req *Request, resp *Response) error {
 	if msg.Method != "" {
 		req.Seq = msg.Seq
 		req.Method = msg.Method
+		req.Stream = msg.Stream
+		req.Header = msg.Header
 	} else {
 		resp.Seq = msg.Seq
 		resp.Error = msg.Error
+		resp.Stream = msg.Stream
 	}
 	return nil
 }
@@ -123,3 +157,11 @@ func (c *gobCodec) WriteResponse(r *Response, body interface{}) (err error) {
 func (c *gobCodec) Close() error {
 	return c.rwc.Close()
 }
+
+// RemoteAddr returns the address of the peer if the connection is a net.Conn.
+func (c *gobCodec) RemoteAddr() net.Addr {
+	if conn, ok := c.rwc.(net.Conn); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
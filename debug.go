@@ -1,12 +1,128 @@
 package birpc
 
-import "log"
+import (
+	"context"
+	"log/slog"
+	"net"
+)
 
-// DebugLog controls the printing of internal and I/O errors.
+// DebugLog controls the logging of internal and I/O errors by the clients and
+// servers that have no logger set. They log to slog.Default, errors always and
+// the rest only when DebugLog is true.
 var DebugLog = false
 
-func debugln(v ...interface{}) {
-	if DebugLog {
-		log.Println(v...)
+// debugLogger is the logger of the clients and servers with no logger set.
+var debugLogger = slog.New(debugHandler{})
+
+// debugHandler passes records to the default slog handler,
+// dropping those below error level unless DebugLog is set.
+type debugHandler struct {
+	wrap func(slog.Handler) slog.Handler // attributes and groups added, if any
+}
+
+func (h debugHandler) handler() slog.Handler {
+	base := slog.Default().Handler()
+	if h.wrap != nil {
+		return h.wrap(base)
+	}
+	return base
+}
+
+func (h debugHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (DebugLog || level >= slog.LevelError) && h.handler().Enabled(ctx, level)
+}
+
+func (h debugHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h debugHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return debugHandler{wrap: h.chain(func(base slog.Handler) slog.Handler { return base.WithAttrs(attrs) })}
+}
+
+func (h debugHandler) WithGroup(name string) slog.Handler {
+	return debugHandler{wrap: h.chain(func(base slog.Handler) slog.Handler { return base.WithGroup(name) })}
+}
+
+func (h debugHandler) chain(next func(slog.Handler) slog.Handler) func(slog.Handler) slog.Handler {
+	prev := h.wrap
+	return func(base slog.Handler) slog.Handler {
+		if prev != nil {
+			base = prev(base)
+		}
+		return next(base)
+	}
+}
+
+// SetLogger makes the client log to l, instead of following DebugLog.
+// Records carry the remote address of the connection, when the codec knows it.
+// It must be called before Run.
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+// SetLogger makes the server and its connections log to l, see Client.SetLogger.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetLogger(l *slog.Logger) {
+	s.logger = l
+}
+
+// SetAccessLog makes the client log every request it handles, at info level,
+// with the method, sequence number, duration and error, to the logger set with
+// SetLogger, or to slog.Default if there is none, whatever DebugLog.
+// It must be called before Run.
+func (c *Client) SetAccessLog(enabled bool) {
+	c.accessLog = enabled
+}
+
+// SetAccessLog makes all connections log the requests they handle, see Client.SetAccessLog.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetAccessLog(enabled bool) {
+	s.accessLog = enabled
+}
+
+// initLoggers adds the remote address to the loggers of the client,
+// and picks the logger of the access records if they are enabled.
+func (c *Client) initLoggers() {
+	if c.accessLog {
+		// Access records are not debug output, so they do not follow DebugLog.
+		c.access = c.logger
+		if c.access == nil {
+			c.access = slog.Default()
+		}
+	}
+	if addr := c.RemoteAddr(); addr != nil {
+		c.logger = c.log().With("remote", addr.String())
+		if c.access != nil {
+			c.access = c.access.With("remote", addr.String())
+		}
+	}
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return debugLogger
+}
+
+func (s *Server) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return debugLogger
+}
+
+// remoteAddresser is implemented by codecs knowing the address of the peer.
+type remoteAddresser interface {
+	RemoteAddr() net.Addr
+}
+
+// RemoteAddr returns the address of the other end of the connection,
+// or nil if the codec does not know it.
+func (c *Client) RemoteAddr() net.Addr {
+	if ra, ok := c.codec.(remoteAddresser); ok {
+		return ra.RemoteAddr()
 	}
+	return nil
 }
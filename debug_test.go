@@ -0,0 +1,106 @@
+package birpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAccessLog(t *testing.T) {
+	var out syncBuffer
+	srv := NewServer()
+	srv.SetLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	srv.SetAccessLog(true)
+	srv.Handle("ok", func(ctx context.Context, _ int, _ *int) error { return nil })
+	srv.Handle("fail", func(ctx context.Context, _ int, _ *int) error { return errors.New("failed") })
+
+	cconn, sconn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeConn(sconn)
+		close(served)
+	}()
+	clt := NewClient(cconn)
+	go clt.Run()
+
+	var reply int
+	clt.Call(context.Background(), "ok", 0, &reply)
+	clt.Call(context.Background(), "fail", 0, &reply)
+	clt.Close()
+	<-served
+
+	want := []string{
+		`level=INFO msg="birpc: request" remote=pipe method=ok seq=1 error=<nil>`,
+		`level=INFO msg="birpc: request" remote=pipe method=fail seq=2 error=failed`,
+	}
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("not expected: %q", got)
+	}
+	for _, line := range want {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("missing %q in:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestAccessLogDefault(t *testing.T) {
+	var out syncBuffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	defer slog.SetDefault(prev)
+
+	// Without a logger, access records go to slog.Default whatever DebugLog.
+	srv := NewServer()
+	srv.SetAccessLog(true)
+	srv.Handle("ok", func(ctx context.Context, _ int, _ *int) error { return nil })
+
+	cconn, sconn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeConn(sconn)
+		close(served)
+	}()
+	clt := NewClient(cconn)
+	go clt.Run()
+
+	var reply int
+	if err := clt.Call(context.Background(), "ok", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+	clt.Close()
+	<-served
+
+	if line := `msg="birpc: request" remote=pipe method=ok seq=1`; !strings.Contains(out.String(), line) {
+		t.Fatalf("missing %q in:\n%s", line, out.String())
+	}
+}
@@ -0,0 +1,74 @@
+package birpc
+
+import (
+	"context"
+	"reflect"
+)
+
+// RawMessage is an encoded value that is passed along without being decoded.
+// With the jsonrpc codec it holds the JSON text, like json.RawMessage.
+// Other codecs only decode a RawMessage from values sent as a []byte.
+type RawMessage []byte
+
+// MarshalJSON returns m as the JSON encoding of m.
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+// UnmarshalJSON sets *m to a copy of data.
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+// FallbackFunc handles requests for methods that have no handler.
+// It receives the name of the method and its undecoded params, and sets the
+// encoded reply. With the jsonrpc codec, params hold the whole params array
+// of the request, and are sent as is when passed to Client.Call.
+// With the gob codec, only requests whose params are a []byte reach the
+// fallback, the others are answered with an error.
+type FallbackFunc func(ctx context.Context, method string, params RawMessage, reply *RawMessage) error
+
+var (
+	typeOfRawMessage    = reflect.TypeOf(RawMessage(nil))
+	typeOfRawMessagePtr = reflect.TypeOf((*RawMessage)(nil))
+)
+
+// SetFallback makes fn handle the requests for methods that have no handler,
+// instead of answering them with "can't find method".
+// It must be called before Run.
+func (c *Client) SetFallback(fn FallbackFunc) {
+	c.fallback = fn
+}
+
+// SetFallback makes fn handle the requests for methods that have no handler
+// on every connection, see Client.SetFallback.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetFallback(fn FallbackFunc) {
+	s.fallback = fn
+}
+
+// fallbackHandler returns the handler calling c.fallback for method.
+func (c *Client) fallbackHandler(method string) *handler {
+	fn := c.fallback
+	return &handler{
+		argType:   typeOfRawMessage,
+		replyType: typeOfRawMessagePtr,
+		fallback:  true,
+		newArgs: func() interface{} {
+			return new(RawMessage)
+		},
+		newReply: func() interface{} {
+			return new(RawMessage)
+		},
+		args: func(decoded interface{}) interface{} {
+			return *decoded.(*RawMessage)
+		},
+		invoke: func(ctx context.Context, decoded, reply interface{}) error {
+			return fn(ctx, method, *decoded.(*RawMessage), reply.(*RawMessage))
+		},
+	}
+}
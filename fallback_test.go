@@ -0,0 +1,56 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("known", func(ctx context.Context, _ int, reply *string) error {
+		*reply = "handler"
+		return nil
+	})
+	srv.SetFallback(func(ctx context.Context, method string, params RawMessage, reply *RawMessage) error {
+		if method == "forbidden" {
+			return errors.New("custom error")
+		}
+		*reply = append(RawMessage(method+":"), params...)
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	var s string
+	if err := clt.Call(context.Background(), "known", 0, &s); err != nil || s != "handler" {
+		t.Fatalf("not expected: %q, %v", s, err)
+	}
+	var raw []byte
+	if err := clt.Call(context.Background(), "dynamic", []byte("params"), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "dynamic:params" {
+		t.Fatalf("not expected: %q", raw)
+	}
+	if err := clt.Call(context.Background(), "forbidden", []byte{}, &raw); err == nil || err.Error() != "custom error" {
+		t.Fatalf("expected custom error, got: %v", err)
+	}
+
+	// Gob cannot pass other params on undecoded, but the connection survives.
+	type Args struct{ A int }
+	if err := clt.Call(context.Background(), "dynamic", Args{A: 1}, &raw); err == nil {
+		t.Fatal("expected error for params the fallback cannot take")
+	}
+	if err := clt.Notify("dynamic", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := clt.Call(context.Background(), "known", 0, &s); err != nil || s != "handler" {
+		t.Fatalf("not expected after a failed fallback: %q, %v", s, err)
+	}
+}
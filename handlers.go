@@ -0,0 +1,79 @@
+package birpc
+
+import (
+	"sort"
+	"sync"
+)
+
+// handlerMap holds the handlers of a Server or Client.
+// It is safe for concurrent use, so handlers can be changed while
+// connections are reading requests.
+//
+// The handlers of a connection served by a Server are layered on top of the
+// server's: methods missing from the connection are looked up in the parent,
+// and registering a method on the connection overrides the server's handler.
+type handlerMap struct {
+	parent *handlerMap // nil if not layered
+	mu     sync.RWMutex
+	m      map[string]*handler
+}
+
+func newHandlerMap(parent *handlerMap) *handlerMap {
+	return &handlerMap{parent: parent, m: make(map[string]*handler)}
+}
+
+func (hm *handlerMap) get(method string) (h *handler, ok bool) {
+	hm.mu.RLock()
+	h, ok = hm.m[method]
+	hm.mu.RUnlock()
+	if !ok && hm.parent != nil {
+		return hm.parent.get(method)
+	}
+	return
+}
+
+// add registers h for method, panicking if method already has a handler
+// in this layer.
+func (hm *handlerMap) add(method string, h *handler) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if _, ok := hm.m[method]; ok {
+		panic("birpc: multiple registrations for " + method)
+	}
+	hm.m[method] = h
+}
+
+// replace registers h for method, replacing any existing handler.
+func (hm *handlerMap) replace(method string, h *handler) {
+	hm.mu.Lock()
+	hm.m[method] = h
+	hm.mu.Unlock()
+}
+
+// remove unregisters the handler of method from this layer, reporting whether there was one.
+func (hm *handlerMap) remove(method string) bool {
+	hm.mu.Lock()
+	_, ok := hm.m[method]
+	delete(hm.m, method)
+	hm.mu.Unlock()
+	return ok
+}
+
+// methods describes the registered handlers, except the internal ones, sorted by name.
+func (hm *handlerMap) methods() []Method {
+	var methods []Method
+	seen := make(map[string]bool)
+	for ; hm != nil; hm = hm.parent {
+		hm.mu.RLock()
+		for name, h := range hm.m {
+			if isInternal(name) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			methods = append(methods, h.describe(name))
+		}
+		hm.mu.RUnlock()
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
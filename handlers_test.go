@@ -0,0 +1,143 @@
+package birpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDynamicHandlers(t *testing.T) {
+	srv := NewServer()
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	call := func() (string, error) {
+		var reply string
+		err := clt.Call(context.Background(), "greet", "birpc", &reply)
+		return reply, err
+	}
+	if _, err := call(); err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("expected missing method, got: %v", err)
+	}
+
+	srv.Handle("greet", func(ctx context.Context, name string, reply *string) error {
+		*reply = "hello " + name
+		return nil
+	})
+	if reply, err := call(); err != nil || reply != "hello birpc" {
+		t.Fatalf("not expected: %q, %v", reply, err)
+	}
+
+	srv.Replace("greet", func(ctx context.Context, name string, reply *string) error {
+		*reply = "hi " + name
+		return nil
+	})
+	if reply, err := call(); err != nil || reply != "hi birpc" {
+		t.Fatalf("not expected: %q, %v", reply, err)
+	}
+
+	if !srv.Unhandle("greet") {
+		t.Fatal("expected greet to be removed")
+	}
+	if srv.Unhandle("greet") || srv.Unhandle("_goRPC_.Cancel") {
+		t.Fatal("removed a method that must not be removed")
+	}
+	if _, err := call(); err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("expected missing method, got: %v", err)
+	}
+
+	// Registering while requests are read must not race.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		method := fmt.Sprintf("m%d", i)
+		go func() {
+			defer wg.Done()
+			srv.Handle(method, func(ctx context.Context, n int, reply *int) error {
+				*reply = n
+				return nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			var reply int
+			clt.Call(context.Background(), method, 1, &reply)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConnHandlers(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("whoami", func(ctx context.Context, _ int, reply *string) error {
+		*reply = "guest"
+		return nil
+	})
+	srv.Handle("login", func(ctx context.Context, name string, reply *bool) error {
+		c := ClientValueFromContext(ctx)
+		c.Handle("whoami", func(ctx context.Context, _ int, reply *string) error {
+			*reply = name
+			return nil
+		})
+		c.Handle("secret", func(ctx context.Context, _ int, reply *string) error {
+			*reply = "42"
+			return nil
+		})
+		*reply = true
+		return nil
+	})
+
+	dial := func() *Client {
+		cconn, sconn := net.Pipe()
+		go srv.ServeCodec(NewGobCodec(sconn))
+		clt := NewClient(cconn)
+		go clt.Run()
+		return clt
+	}
+	alice, other := dial(), dial()
+	defer alice.Close()
+	defer other.Close()
+
+	var ok bool
+	if err := alice.Call(context.Background(), "login", "alice", &ok); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		clt    *Client
+		method string
+		want   string
+	}{
+		{alice, "whoami", "alice"},
+		{alice, "secret", "42"},
+		{other, "whoami", "guest"},
+	} {
+		var reply string
+		if err := tc.clt.Call(context.Background(), tc.method, 0, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply != tc.want {
+			t.Fatalf("%s: not expected: %q", tc.method, reply)
+		}
+	}
+	var reply string
+	if err := other.Call(context.Background(), "secret", 0, &reply); err == nil {
+		t.Fatal("secret must only be available to alice")
+	}
+
+	methods, err := alice.ListMethods(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 3 {
+		t.Fatalf("not expected: %+v", methods)
+	}
+	if methods, _ = other.ListMethods(context.Background(), ""); len(methods) != 2 {
+		t.Fatalf("not expected: %+v", methods)
+	}
+}
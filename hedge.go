@@ -0,0 +1,186 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// hedgeSamples is the number of recent latencies a Hedger keeps.
+	hedgeSamples = 100
+	// hedgeMinSamples is the number of latencies needed before a Hedger uses the percentile.
+	hedgeMinSamples = 20
+)
+
+// HedgePolicy configures when a Hedger sends a call again.
+type HedgePolicy struct {
+	// Delay is how long to wait for a reply before sending the call again.
+	Delay time.Duration
+	// Percentile, if set, waits for the given percentile of the recent
+	// latencies instead, between 0 and 1, like 0.95. Delay is used until
+	// enough calls have been made.
+	Percentile float64
+	// MaxHedges is the number of extra calls made at most, 1 by default.
+	MaxHedges int
+}
+
+// Hedger makes latency sensitive calls to replicated servers.
+// If a call has not been answered in time, it sends the same call to the
+// next client, and the first successful reply wins. The calls that lost are
+// cancelled. A call that fails is sent to the next client at once.
+//
+// Only idempotent methods should be hedged, since several servers may handle
+// the same call.
+type Hedger struct {
+	clients []*Client
+	policy  HedgePolicy
+	next    uint32 // client of the next first attempt
+
+	mu        sync.Mutex
+	latencies []time.Duration // ring of recent latencies
+	pos       int
+}
+
+// NewHedger returns a Hedger spreading calls over clients, which must be running.
+func NewHedger(clients []*Client, policy HedgePolicy) *Hedger {
+	if len(clients) == 0 {
+		panic("birpc: hedger needs clients")
+	}
+	if policy.MaxHedges <= 0 {
+		policy.MaxHedges = 1
+	}
+	return &Hedger{
+		clients: clients,
+		policy:  policy,
+	}
+}
+
+// Call invokes the named function, hedging it as configured.
+// Hedges are not sent when the deadline of ctx expires before they are due.
+// reply must be a pointer, each attempt decodes into its own value.
+func (h *Hedger) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	rv := reflect.ValueOf(reply)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("birpc: hedged call reply must be a non nil pointer")
+	}
+	maxAttempts := h.policy.MaxHedges + 1
+	if maxAttempts > len(h.clients) {
+		maxAttempts = len(h.clients)
+	}
+	type attempt struct {
+		c     *Client
+		reply reflect.Value
+		start time.Time
+	}
+	// Room for every attempt and the answer to its cancel request.
+	done := make(chan *Call, 2*maxAttempts)
+	attempts := make(map[*Call]attempt, maxAttempts)
+	first := int(atomic.AddUint32(&h.next, 1))
+	sent := 0
+	send := func() {
+		c := h.clients[(first+sent)%len(h.clients)]
+		a := attempt{c: c, reply: reflect.New(rv.Type().Elem()), start: time.Now()}
+		attempts[c.goContext(ctx, method, args, a.reply.Interface(), done)] = a
+		sent++
+	}
+	cancelOthers := func(winner *Call) {
+		for call, a := range attempts {
+			if call != winner {
+				a.c.cancel(call, context.Canceled)
+			}
+		}
+	}
+
+	delay := h.delay()
+	deadline, hasDeadline := ctx.Deadline()
+	var (
+		timer *time.Timer
+		hedge <-chan time.Time
+	)
+	schedule := func() {
+		if sent >= maxAttempts || hasDeadline && time.Until(deadline) <= delay {
+			return
+		}
+		if timer == nil {
+			timer = time.NewTimer(delay)
+		} else {
+			timer.Reset(delay)
+		}
+		hedge = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	send()
+	schedule()
+	for {
+		select {
+		case call := <-done:
+			a, ok := attempts[call]
+			if !ok {
+				continue // answer to a cancel request
+			}
+			delete(attempts, call)
+			if call.Error == nil {
+				h.observe(time.Since(a.start))
+				rv.Elem().Set(a.reply.Elem())
+				cancelOthers(call)
+				return nil
+			}
+			if sent < maxAttempts {
+				send()
+			} else if len(attempts) == 0 {
+				return call.Error
+			}
+		case <-hedge:
+			hedge = nil
+			if sent < maxAttempts { // a failed attempt may have been replaced meanwhile
+				send()
+				schedule()
+			}
+		case <-ctx.Done():
+			cancelOthers(nil)
+			return ctx.Err()
+		}
+	}
+}
+
+// delay returns how long to wait before sending a hedge.
+func (h *Hedger) delay() time.Duration {
+	if h.policy.Percentile <= 0 {
+		return h.policy.Delay
+	}
+	h.mu.Lock()
+	if len(h.latencies) < hedgeMinSamples {
+		h.mu.Unlock()
+		return h.policy.Delay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(h.policy.Percentile * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// observe records the latency of a successful call.
+func (h *Hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, d)
+	} else {
+		h.latencies[h.pos] = d
+		h.pos = (h.pos + 1) % hedgeSamples
+	}
+	h.mu.Unlock()
+}
@@ -0,0 +1,56 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHedger(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	dial := func(name string, latency time.Duration) *Client {
+		srv := NewServer()
+		srv.Handle("get", func(ctx context.Context, _ int, reply *string) error {
+			select {
+			case <-time.After(latency):
+				*reply = name
+				return nil
+			case <-ctx.Done():
+				cancelled <- struct{}{}
+				return ctx.Err()
+			}
+		})
+		cconn, sconn := net.Pipe()
+		go srv.ServeCodec(NewGobCodec(sconn))
+		clt := NewClient(cconn)
+		go clt.Run()
+		return clt
+	}
+	fast, slow := dial("fast", 0), dial("slow", time.Minute)
+	defer fast.Close()
+	defer slow.Close()
+
+	// The first call goes to the slow server, the hedge to the fast one.
+	h := NewHedger([]*Client{fast, slow}, HedgePolicy{Delay: 10 * time.Millisecond})
+	var reply string
+	if err := h.Call(context.Background(), "get", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "fast" {
+		t.Fatalf("not expected: %q", reply)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow call was not cancelled")
+	}
+
+	// No hedge is sent past the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	h.next = 0
+	if err := h.Call(ctx, "get", 0, &reply); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got: %v", err)
+	}
+}
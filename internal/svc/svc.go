@@ -64,3 +64,9 @@ func (s *GoRPC) Cancel(ctx context.Context, args *CancelArgs, reply *bool) error
 	args.pending.Cancel(args.Seq)
 	return nil
 }
+
+// Ping answers keepalive probes, echoing args back.
+func (s *GoRPC) Ping(ctx context.Context, args uint64, reply *uint64) error {
+	*reply = args
+	return nil
+}
@@ -0,0 +1,91 @@
+package birpc
+
+import (
+	"context"
+	"path"
+	"reflect"
+)
+
+// methodsMethod lists the methods registered on the peer.
+const methodsMethod = "_goRPC_.Methods"
+
+// Method describes a registered handler.
+type Method struct {
+	Name      string
+	ArgType   reflect.Type // type of the arguments, a channel for client streaming methods
+	ReplyType reflect.Type // type of the reply, without the pointer
+	Upload    bool         // the arguments are streamed by the caller
+	Stream    bool         // the reply is streamed by the handler
+}
+
+// MethodInfo describes a method registered on the peer, as returned by ListMethods.
+type MethodInfo struct {
+	Name   string
+	Args   string // Go type of the arguments
+	Reply  string // Go type of the reply
+	Upload bool
+	Stream bool
+}
+
+// Methods returns the methods registered on the server, sorted by name.
+// The internal _goRPC_ methods are left out.
+func (s *Server) Methods() []Method {
+	return s.handlers.methods()
+}
+
+// Methods returns the methods this client answers, sorted by name.
+// The internal _goRPC_ methods are left out.
+func (c *Client) Methods() []Method {
+	return c.handlers.methods()
+}
+
+// ListMethods asks the peer for the methods it has registered whose name
+// matches pattern, in the syntax of path.Match. An empty pattern matches all.
+func (c *Client) ListMethods(ctx context.Context, pattern string) ([]MethodInfo, error) {
+	var methods []MethodInfo
+	err := c.Call(ctx, methodsMethod, pattern, &methods)
+	return methods, err
+}
+
+func (h *handler) describe(name string) Method {
+	m := Method{
+		Name:      name,
+		ArgType:   h.argType,
+		ReplyType: h.replyType.Elem(),
+		Upload:    h.upload,
+		Stream:    h.stream,
+	}
+	if h.stream {
+		m.ReplyType = nil // the items sent on the stream have no static type
+	}
+	return m
+}
+
+// listMethods handles _goRPC_.Methods, listing the methods of the calling connection
+// that its ACL lets the peer call.
+func listMethods(ctx context.Context, pattern string, reply *[]MethodInfo) error {
+	c := ClientValueFromContext(ctx)
+	for _, m := range c.handlers.methods() {
+		if c.acl != nil && !c.acl.Permitted(m.Name, c.State) {
+			continue
+		}
+		if pattern != "" {
+			if ok, err := path.Match(pattern, m.Name); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+		info := MethodInfo{
+			Name:   m.Name,
+			Args:   m.ArgType.String(),
+			Upload: m.Upload,
+			Stream: m.Stream,
+		}
+		if m.ReplyType != nil {
+			info.Reply = m.ReplyType.String()
+		}
+		*reply = append(*reply, info)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestListMethods(t *testing.T) {
+	type Args struct{ A, B int }
+
+	srv := NewServer()
+	srv.Handle("Calc.Add", func(ctx context.Context, args *Args, reply *int) error {
+		*reply = args.A + args.B
+		return nil
+	})
+	srv.Handle("Calc.Sum", func(ctx context.Context, nums <-chan int, reply *int) error {
+		return nil
+	})
+	srv.Handle("echo", func(ctx context.Context, s string, reply *string) error {
+		*reply = s
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	methods, err := clt.ListMethods(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MethodInfo{
+		{Name: "Calc.Add", Args: "*birpc.Args", Reply: "int"},
+		{Name: "Calc.Sum", Args: "<-chan int", Reply: "int", Upload: true},
+		{Name: "echo", Args: "string", Reply: "string"},
+	}
+	if !reflect.DeepEqual(methods, want) {
+		t.Fatalf("not expected: %+v", methods)
+	}
+
+	if methods, err = clt.ListMethods(context.Background(), "Calc.*"); err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 2 {
+		t.Fatalf("not expected: %+v", methods)
+	}
+
+	if len(clt.Methods()) != 0 {
+		t.Fatalf("client has no methods, got: %v", clt.Methods())
+	}
+}
+
+func TestListMethodsACL(t *testing.T) {
+	acl := NewACL()
+	acl.Allow("Accounts.Delete", "admin")
+
+	srv := NewServer()
+	srv.SetACL(acl)
+	srv.Handle("Accounts.Get", func(ctx context.Context, id int, reply *int) error { return nil })
+	srv.Handle("Accounts.Delete", func(ctx context.Context, id int, reply *bool) error { return nil })
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodecWithState(NewGobCodec(sconn), stateWith(RolesKey, "user"))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	methods, err := clt.ListMethods(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 1 || methods[0].Name != "Accounts.Get" {
+		t.Fatalf("not expected: %+v", methods)
+	}
+}
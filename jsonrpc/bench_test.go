@@ -0,0 +1,50 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	birpc "github.com/cgrates/birpc"
+)
+
+type BenchArgs struct{ A, B int }
+
+func benchmarkCall(b *testing.B, register func(*birpc.Server)) {
+	srv := birpc.NewServer()
+	register(srv)
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(NewJSONCodec(cconn))
+	go clt.Run()
+	defer clt.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var reply int
+		for pb.Next() {
+			if err := clt.Call(context.Background(), "add", BenchArgs{1, 2}, &reply); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkJSONReflect(b *testing.B) {
+	benchmarkCall(b, func(srv *birpc.Server) {
+		srv.Handle("add", func(ctx context.Context, args *BenchArgs, reply *int) error {
+			*reply = args.A + args.B
+			return nil
+		})
+	})
+}
+
+func BenchmarkJSONTyped(b *testing.B) {
+	benchmarkCall(b, func(srv *birpc.Server) {
+		birpc.HandleFunc(srv, "add", func(ctx context.Context, args *BenchArgs, reply *int) error {
+			*reply = args.A + args.B
+			return nil
+		})
+	})
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"reflect"
 	"sync"
 
@@ -41,8 +42,12 @@ type jsonCodec struct {
 	// but save the original request ID in the pending map.
 	// When rpc responds, we use the sequence number in
 	// the response to find the original request ID.
-	mutex   sync.Mutex // protects seq, pending
+	// Stream frames sent by the caller of a streaming call reuse
+	// the ID of the request that opened it, which ids maps back to
+	// the sequence number.
+	mutex   sync.Mutex // protects seq, pending, ids
 	pending map[uint64]*json.RawMessage
+	ids     map[string]uint64
 	seq     uint64
 }
 
@@ -53,16 +58,19 @@ func NewJSONCodec(conn io.ReadWriteCloser) birpc.Codec {
 		enc:     json.NewEncoder(conn),
 		c:       conn,
 		pending: make(map[uint64]*json.RawMessage),
+		ids:     make(map[string]uint64),
 	}
 }
 
 // serverRequest and clientResponse combined
 type message struct {
-	Method string           `json:"method"`
-	Params *json.RawMessage `json:"params"`
-	Id     *json.RawMessage `json:"id"`
-	Result *json.RawMessage `json:"result"`
-	Error  interface{}      `json:"error"`
+	Method string            `json:"method"`
+	Params *json.RawMessage  `json:"params"`
+	Id     *json.RawMessage  `json:"id"`
+	Result *json.RawMessage  `json:"result"`
+	Error  interface{}       `json:"error"`
+	Stream birpc.StreamFrame `json:"stream,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
 }
 
 // Unmarshal to
@@ -79,14 +87,17 @@ type clientResponse struct {
 
 // to Marshal
 type serverResponse struct {
-	Id     *json.RawMessage `json:"id"`
-	Result interface{}      `json:"result"`
-	Error  interface{}      `json:"error"`
+	Id     *json.RawMessage  `json:"id"`
+	Result interface{}       `json:"result"`
+	Error  interface{}       `json:"error"`
+	Stream birpc.StreamFrame `json:"stream,omitempty"`
 }
 type clientRequest struct {
-	Method string      `json:"method"`
-	Params interface{} `json:"params"`
-	Id     *uint64     `json:"id"`
+	Method string            `json:"method"`
+	Params interface{}       `json:"params"`
+	Id     *uint64           `json:"id"`
+	Stream birpc.StreamFrame `json:"stream,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
 }
 
 func (c *jsonCodec) ReadHeader(req *birpc.Request, resp *birpc.Response) error {
@@ -102,16 +113,36 @@ func (c *jsonCodec) ReadHeader(req *birpc.Request, resp *birpc.Response) error {
 		c.serverRequest.Params = c.msg.Params
 
 		req.Method = c.serverRequest.Method
+		req.Stream = c.msg.Stream
+		req.Header = c.msg.Header
 
 		// JSON request id can be any JSON value;
 		// RPC package expects uint64.  Translate to
 		// internal uint64 and save JSON on the side.
-		if c.serverRequest.Id == nil {
+		switch {
+		case c.serverRequest.Id == nil:
 			// Notification
-		} else {
+		case req.Stream != 0 && req.Stream != birpc.StreamOpen:
+			// Frame of an open stream, zero if the stream is gone.
+			c.mutex.Lock()
+			req.Seq = c.ids[string(*c.serverRequest.Id)]
+			c.serverRequest.Id = nil
+			c.mutex.Unlock()
+		default:
 			c.mutex.Lock()
 			c.seq++
-			c.pending[c.seq] = c.serverRequest.Id
+			id := string(*c.serverRequest.Id)
+			if _, open := c.ids[id]; open && req.Stream == birpc.StreamOpen {
+				// The frames of both streams would carry the same id,
+				// so the request is answered without it.
+				req.Error = "jsonrpc: stream id " + id + " already open"
+				c.pending[c.seq] = nil
+			} else {
+				c.pending[c.seq] = c.serverRequest.Id
+				if req.Stream == birpc.StreamOpen {
+					c.ids[id] = c.seq
+				}
+			}
 			c.serverRequest.Id = nil
 			req.Seq = c.seq
 			c.mutex.Unlock()
@@ -127,6 +158,7 @@ func (c *jsonCodec) ReadHeader(req *birpc.Request, resp *birpc.Response) error {
 
 		resp.Error = ""
 		resp.Seq = c.clientResponse.Id
+		resp.Stream = c.msg.Stream
 		if c.clientResponse.Error != nil || c.clientResponse.Result == nil {
 			x, ok := c.clientResponse.Error.(string)
 			if !ok {
@@ -175,7 +207,7 @@ func (c *jsonCodec) ReadResponseBody(x interface{}) error {
 }
 
 func (c *jsonCodec) WriteRequest(r *birpc.Request, param interface{}) error {
-	req := &clientRequest{Method: r.Method}
+	req := &clientRequest{Method: r.Method, Stream: r.Stream, Header: r.Header}
 
 	// Check if param is a slice of any kind
 	if param != nil && reflect.TypeOf(param).Kind() == reflect.Slice {
@@ -205,14 +237,20 @@ func (c *jsonCodec) WriteResponse(r *birpc.Response, x interface{}) error {
 		c.mutex.Unlock()
 		return errors.New("invalid sequence number in response")
 	}
-	delete(c.pending, r.Seq)
+	if r.Stream == 0 {
+		// Stream frames leave the request pending until its final response.
+		delete(c.pending, r.Seq)
+		if b != nil && c.ids[string(*b)] == r.Seq {
+			delete(c.ids, string(*b))
+		}
+	}
 	c.mutex.Unlock()
 
 	if b == nil {
 		// Invalid request so no id.  Use JSON null.
 		b = &null
 	}
-	resp := serverResponse{Id: b}
+	resp := serverResponse{Id: b, Stream: r.Stream}
 	if r.Error == "" {
 		resp.Result = x
 	} else {
@@ -224,3 +262,11 @@ func (c *jsonCodec) WriteResponse(r *birpc.Response, x interface{}) error {
 func (c *jsonCodec) Close() error {
 	return c.c.Close()
 }
+
+// RemoteAddr returns the address of the peer if the connection is a net.Conn.
+func (c *jsonCodec) RemoteAddr() net.Addr {
+	if conn, ok := c.c.(net.Conn); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -186,3 +187,189 @@ func TestJSONRPC(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestJSONRPCStream(t *testing.T) {
+	type Record struct{ N int }
+
+	srv := birpc.NewServer()
+	srv.Handle("export", func(ctx context.Context, n int, stream *birpc.ServerStream) error {
+		for i := 0; i < n; i++ {
+			if err := stream.Send(&Record{N: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(NewJSONCodec(cconn))
+	go clt.Run()
+	defer clt.Close()
+
+	const n = 500
+	stream := clt.Stream(context.TODO(), "export", n, new(Record))
+	for i := 0; i < n; i++ {
+		item, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec := item.(*Record); rec.N != i {
+			t.Fatalf("not expected: %d", rec.N)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF, got: %v", err)
+	}
+}
+
+func TestJSONRPCUpload(t *testing.T) {
+	type CDR struct{ Cost int }
+
+	srv := birpc.NewServer()
+	srv.Handle("store", func(ctx context.Context, cdrs <-chan CDR, total *int) error {
+		for cdr := range cdrs {
+			*total += cdr.Cost
+		}
+		return ctx.Err()
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(NewJSONCodec(cconn))
+	go clt.Run()
+	defer clt.Close()
+
+	const n = 500
+	var total int
+	up := clt.Upload(context.TODO(), "store", &total)
+	for i := 1; i <= n; i++ {
+		if err := up.Send(CDR{Cost: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := up.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if total != n*(n+1)/2 {
+		t.Fatalf("not expected: %d", total)
+	}
+}
+
+func TestJSONRPCFallback(t *testing.T) {
+	srv := birpc.NewServer()
+	srv.SetFallback(func(ctx context.Context, method string, params birpc.RawMessage, reply *birpc.RawMessage) error {
+		*reply = birpc.RawMessage(fmt.Sprintf(`{"method":%q,"params":%s}`, method, params))
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(NewJSONCodec(cconn))
+	go clt.Run()
+	defer clt.Close()
+
+	var reply struct {
+		Method string
+		Params []interface{}
+	}
+	if err := clt.Call(context.TODO(), "Script.Run", map[string]int{"a": 1}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Method != "Script.Run" || fmt.Sprint(reply.Params) != "[map[a:1]]" {
+		t.Fatalf("not expected: %+v", reply)
+	}
+
+	// Raw params are sent as they are.
+	var raw birpc.RawMessage
+	if err := clt.Call(context.TODO(), "echo", birpc.RawMessage(`[1,"two"]`), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `{"method":"echo","params":[1,"two"]}` {
+		t.Fatalf("not expected: %s", raw)
+	}
+}
+
+type headerTracer struct{ got chan map[string]string }
+
+func (t headerTracer) StartCall(ctx context.Context, method string, header map[string]string) func(error) {
+	header["trace"] = method
+	return func(error) {}
+}
+
+func (t headerTracer) StartHandler(ctx context.Context, method string, header map[string]string) (context.Context, func(error)) {
+	t.got <- header
+	return ctx, func(error) {}
+}
+
+func TestJSONRPCHeader(t *testing.T) {
+	tracer := headerTracer{got: make(chan map[string]string, 1)}
+	srv := birpc.NewServer()
+	srv.SetTracer(tracer)
+	srv.Handle("echo", func(ctx context.Context, n int, reply *int) error {
+		*reply = n
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(NewJSONCodec(cconn))
+	clt.SetTracer(tracer)
+	go clt.Run()
+	defer clt.Close()
+
+	var reply int
+	if err := clt.Call(context.TODO(), "echo", 1, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if header := <-tracer.got; header["trace"] != "echo" {
+		t.Fatalf("not expected: %v", header)
+	}
+}
+
+func TestJSONRPCDuplicateStream(t *testing.T) {
+	release := make(chan struct{})
+	srv := birpc.NewServer()
+	srv.Handle("export", func(ctx context.Context, n int, stream *birpc.ServerStream) error {
+		<-release
+		return stream.Send(n)
+	})
+
+	cconn, sconn := net.Pipe()
+	defer cconn.Close()
+	go srv.ServeCodec(NewJSONCodec(sconn))
+	enc, dec := json.NewEncoder(cconn), json.NewDecoder(cconn)
+	cconn.SetDeadline(time.Now().Add(time.Second))
+
+	open := map[string]interface{}{"method": "export", "params": []int{7}, "id": "a", "stream": birpc.StreamOpen}
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(open); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type response struct {
+		Id     *json.RawMessage
+		Error  interface{}
+		Stream birpc.StreamFrame
+	}
+	var resp response
+	// The second open is answered without its id, which belongs to the first.
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != nil || resp.Error == nil {
+		t.Fatalf("not expected: %+v", resp)
+	}
+
+	// The first stream goes on.
+	close(release)
+	for _, want := range []birpc.StreamFrame{birpc.StreamData, 0} {
+		resp = response{}
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Id == nil || string(*resp.Id) != `"a"` || resp.Stream != want || resp.Error != nil {
+			t.Fatalf("not expected: %+v", resp)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package birpc
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// pingMethod is the internal method called to check that the other end is alive.
+const pingMethod = "_goRPC_.Ping"
+
+var (
+	// ErrKeepaliveTimeout is returned for calls pending on a connection
+	// that stopped answering keepalive pings.
+	ErrKeepaliveTimeout = errors.New("birpc: keepalive timeout")
+
+	// ErrIdleTimeout is returned for calls pending on a connection
+	// that was closed because it carried no traffic.
+	ErrIdleTimeout = errors.New("birpc: idle timeout")
+)
+
+// SetKeepalive makes the client ping the other end of the connection every interval.
+// If maxMissed pings in a row go unanswered, the connection is declared dead:
+// the codec is closed and pending calls fail with ErrKeepaliveTimeout.
+// Any answer counts, so peers that do not know the ping method are still
+// considered alive. A zero interval disables pings. It must be called before Run.
+func (c *Client) SetKeepalive(interval time.Duration, maxMissed int) {
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+	c.keepalive = interval
+	c.maxMissed = maxMissed
+}
+
+// SetIdleTimeout closes the connection once it has carried no calls,
+// notifications or responses for timeout. Keepalive pings do not count as traffic.
+// Pending calls fail with ErrIdleTimeout. A zero timeout disables it.
+// It must be called before Run.
+func (c *Client) SetIdleTimeout(timeout time.Duration) {
+	c.idle = timeout
+}
+
+// touch records traffic on the connection.
+func (c *Client) touch() {
+	atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+}
+
+// fail closes the connection on behalf of the client,
+// making pending calls fail with err.
+func (c *Client) fail(err error) {
+	c.mutex.Lock()
+	if c.shutdown || c.closing {
+		c.mutex.Unlock()
+		return
+	}
+	c.failErr = err
+	c.mutex.Unlock()
+	c.markClosed()
+	c.log().Debug("birpc: closing connection", "error", err)
+	c.codec.Close()
+}
+
+func (c *Client) keepaliveLoop() {
+	ticker := time.NewTicker(c.keepalive)
+	defer ticker.Stop()
+	var (
+		ping   chan *Call // outstanding ping, if any
+		nonce  uint64
+		missed int
+	)
+	for {
+		select {
+		case <-c.disconnect:
+			return
+		case <-ticker.C:
+		}
+		if ping != nil {
+			select {
+			case call := <-ping:
+				if _, ok := call.Error.(ServerError); call.Error == nil || ok {
+					missed = 0
+				} else {
+					missed++
+				}
+				ping = nil
+			default:
+				// Still waiting for the answer.
+				missed++
+			}
+		}
+		if missed >= c.maxMissed {
+			c.fail(ErrKeepaliveTimeout)
+			return
+		}
+		if ping == nil {
+			// Writing may block on a dead connection,
+			// so the ping is sent from its own goroutine.
+			ping = make(chan *Call, 1)
+			nonce++
+			go c.Go(pingMethod, nonce, new(uint64), ping)
+		}
+	}
+}
+
+func (c *Client) idleLoop() {
+	timer := time.NewTimer(c.idle)
+	defer timer.Stop()
+	for {
+		select {
+		case <-c.disconnect:
+			return
+		case <-timer.C:
+		}
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastActive)))
+		if idle >= c.idle {
+			c.fail(ErrIdleTimeout)
+			return
+		}
+		timer.Reset(c.idle - idle)
+	}
+}
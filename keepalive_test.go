@@ -0,0 +1,84 @@
+package birpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestKeepaliveTimeout(t *testing.T) {
+	cconn, sconn := net.Pipe()
+	// The other end reads everything and never answers, like a peer
+	// behind a half-open connection.
+	go io.Copy(io.Discard, sconn)
+
+	clt := NewClient(cconn)
+	clt.SetKeepalive(20*time.Millisecond, 2)
+	go clt.Run()
+
+	call := clt.Go("foo", 1, new(int), nil)
+	select {
+	case <-clt.DisconnectNotify():
+	case <-time.After(time.Second):
+		t.Fatal("dead connection was not detected")
+	}
+	<-call.Done
+	if call.Error != ErrKeepaliveTimeout {
+		t.Fatalf("expected ErrKeepaliveTimeout, got: %v", call.Error)
+	}
+}
+
+func TestKeepaliveAlive(t *testing.T) {
+	srv := NewServer()
+	srv.SetKeepalive(10*time.Millisecond, 2)
+	srv.Handle("echo", func(ctx context.Context, i int, reply *int) error {
+		*reply = i
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	clt.SetKeepalive(10*time.Millisecond, 2)
+	go clt.Run()
+	defer clt.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	var rep int
+	if err := clt.Call(context.Background(), "echo", 7, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep != 7 {
+		t.Fatalf("not expected: %d", rep)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	srv := NewServer()
+	srv.SetKeepalive(5*time.Millisecond, 2)
+	srv.SetIdleTimeout(50 * time.Millisecond)
+	srv.Handle("echo", func(ctx context.Context, i int, reply *int) error {
+		*reply = i
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+
+	var rep int
+	for i := 0; i < 3; i++ {
+		time.Sleep(25 * time.Millisecond)
+		if err := clt.Call(context.Background(), "echo", i, &rep); err != nil {
+			t.Fatalf("connection closed while in use: %v", err)
+		}
+	}
+	select {
+	case <-clt.DisconnectNotify():
+	case <-time.After(time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+}
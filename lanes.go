@@ -0,0 +1,74 @@
+package birpc
+
+import "sync"
+
+const (
+	// maxLanes is the number of keys a connection handles requests for at once.
+	maxLanes = 1024
+	// maxLaneQueue is the number of requests waiting in a lane at most.
+	maxLaneQueue = 256
+)
+
+// KeyFunc extracts an ordering key from the decoded arguments of a request,
+// for example a session ID. Requests with the same non-empty key are handled
+// one at a time, in the order they were received. Requests with different
+// keys, or with an empty key, are handled concurrently.
+type KeyFunc func(method string, args interface{}) string
+
+// lanes runs the tasks that share a key one after the other,
+// each key on its own goroutine.
+type lanes struct {
+	mu       sync.Mutex
+	queues   map[string][]func() // tasks waiting behind the running one, per key
+	maxLanes int
+	maxQueue int
+}
+
+func newLanes() *lanes {
+	return &lanes{
+		queues:   make(map[string][]func()),
+		maxLanes: maxLanes,
+		maxQueue: maxLaneQueue,
+	}
+}
+
+// push runs task after the tasks previously pushed with the same key.
+// It returns ErrServerBusy if there are too many lanes running,
+// or too many tasks waiting in the lane of key.
+func (l *lanes) push(key string, task func()) error {
+	l.mu.Lock()
+	if q, ok := l.queues[key]; ok {
+		if len(q) >= l.maxQueue {
+			l.mu.Unlock()
+			return ErrServerBusy
+		}
+		l.queues[key] = append(q, task)
+		l.mu.Unlock()
+		return nil
+	}
+	if len(l.queues) >= l.maxLanes {
+		l.mu.Unlock()
+		return ErrServerBusy
+	}
+	l.queues[key] = nil // mark the lane as running
+	l.mu.Unlock()
+	go l.run(key, task)
+	return nil
+}
+
+func (l *lanes) run(key string, task func()) {
+	for {
+		task()
+		l.mu.Lock()
+		q := l.queues[key]
+		if len(q) == 0 {
+			delete(l.queues, key)
+			l.mu.Unlock()
+			return
+		}
+		task = q[0]
+		q[0] = nil
+		l.queues[key] = q[1:]
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,112 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedDispatch(t *testing.T) {
+	type Event struct {
+		Session string
+		N       int
+	}
+
+	srv := NewServer()
+	srv.SetKeyFunc(func(method string, args interface{}) string {
+		if ev, ok := args.(*Event); ok {
+			return ev.Session
+		}
+		return ""
+	})
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	bStarted := make(chan struct{})
+	srv.Handle("event", func(ctx context.Context, ev *Event, _ *bool) error {
+		switch {
+		case ev.Session == "a" && ev.N == 0:
+			// Session b must not be held back by session a.
+			select {
+			case <-bStarted:
+			case <-time.After(time.Second):
+				t.Error("sessions were not handled concurrently")
+			}
+		case ev.Session == "b" && ev.N == 0:
+			close(bStarted)
+		default:
+			time.Sleep(time.Duration(5-ev.N%5) * time.Millisecond)
+		}
+		mu.Lock()
+		got[ev.Session] = append(got[ev.Session], ev.N)
+		mu.Unlock()
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	const n = 10
+	done := make(chan *Call, 2*n)
+	for i := 0; i < n; i++ {
+		for _, s := range []string{"a", "b"} {
+			clt.Go("event", &Event{Session: s, N: i}, new(bool), done)
+		}
+	}
+	for i := 0; i < 2*n; i++ {
+		if call := <-done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	for _, s := range []string{"a", "b"} {
+		if len(got[s]) != n {
+			t.Fatalf("session %s: got %d events", s, len(got[s]))
+		}
+		for i, v := range got[s] {
+			if v != i {
+				t.Fatalf("session %s handled out of order: %v", s, got[s])
+			}
+		}
+	}
+}
+
+func TestLaneLimits(t *testing.T) {
+	l := newLanes()
+	l.maxLanes, l.maxQueue = 2, 1
+	release := make(chan struct{})
+	block := func() { <-release }
+	for _, key := range []string{"a", "b"} {
+		if err := l.push(key, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.push("c", block); err != ErrServerBusy {
+		t.Fatalf("expected busy for a third lane, got: %v", err)
+	}
+	if err := l.push("a", block); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.push("a", block); err != ErrServerBusy {
+		t.Fatalf("expected busy for a full queue, got: %v", err)
+	}
+	close(release)
+	for deadline := time.Now().Add(time.Second); ; {
+		l.mu.Lock()
+		n := len(l.queues)
+		l.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("lanes did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := l.push("c", func() {}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package birpc
+
+import (
+	"time"
+
+	"github.com/cenkalti/hub"
+)
+
+// Metrics receives the measurements of a Client or Server,
+// to be recorded with a metrics library, see the prometheus package.
+// Internal _goRPC_ calls are not measured, and requests passed to the
+// FallbackFunc are reported as FallbackMethod, as their names are chosen by
+// the peer. Its methods are called concurrently and must not block.
+type Metrics interface {
+	// CallDone is called when an outgoing call completes, fails or is cancelled.
+	CallDone(method string, err error, elapsed time.Duration)
+	// HandlerDone is called when a handler returns.
+	HandlerDone(method string, err error, elapsed time.Duration)
+	// InFlight is called with 1 when a handler starts and -1 when it returns.
+	InFlight(method string, delta int)
+	// ConnOpened is called when a connection starts being served.
+	ConnOpened()
+	// ConnClosed is called when a connection goes away.
+	ConnClosed()
+}
+
+// FallbackMethod is the method reported to Metrics for the requests
+// handled by the FallbackFunc.
+const FallbackMethod = "_fallback"
+
+// SetMetrics makes the client report its calls, handlers and connection to m.
+// It must be called before Run.
+func (c *Client) SetMetrics(m Metrics) {
+	c.metrics = m
+}
+
+// SetMetrics makes the server report the calls, handlers and connections of
+// all its connections to m, replacing the Metrics set before, if any.
+// Passing nil stops reporting.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetMetrics(m Metrics) {
+	for _, unsubscribe := range s.metricsSubs {
+		unsubscribe()
+	}
+	s.metrics, s.metricsSubs = m, nil
+	if m == nil {
+		return
+	}
+	s.metricsSubs = []func(){
+		s.eventHub.Subscribe(clientConnected, func(hub.Event) {
+			m.ConnOpened()
+		}),
+		s.eventHub.Subscribe(clientDisconnected, func(hub.Event) {
+			m.ConnClosed()
+		}),
+	}
+}
@@ -0,0 +1,97 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedMetrics struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (m *recordedMetrics) record(format string, args ...interface{}) {
+	m.mu.Lock()
+	m.events = append(m.events, fmt.Sprintf(format, args...))
+	m.mu.Unlock()
+}
+
+func (m *recordedMetrics) CallDone(method string, err error, _ time.Duration) {
+	m.record("call %s %v", method, err)
+}
+
+func (m *recordedMetrics) HandlerDone(method string, err error, _ time.Duration) {
+	m.record("handler %s %v", method, err)
+}
+
+func (m *recordedMetrics) InFlight(method string, delta int) {
+	m.record("inflight %s %d", method, delta)
+}
+
+func (m *recordedMetrics) ConnOpened() {
+	m.record("open")
+}
+
+func (m *recordedMetrics) ConnClosed() {
+	m.record("close")
+}
+
+func (m *recordedMetrics) sorted() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := append([]string(nil), m.events...)
+	sort.Strings(events)
+	return events
+}
+
+func TestMetrics(t *testing.T) {
+	srvMetrics, cltMetrics := new(recordedMetrics), new(recordedMetrics)
+	srv := NewServer()
+	replaced := new(recordedMetrics)
+	srv.SetMetrics(replaced)
+	srv.SetMetrics(srvMetrics) // replaces the first one
+	srv.Handle("ok", func(ctx context.Context, _ int, _ *int) error { return nil })
+	srv.Handle("fail", func(ctx context.Context, _ int, _ *int) error { return errors.New("failed") })
+	srv.SetFallback(func(ctx context.Context, method string, params RawMessage, reply *RawMessage) error {
+		*reply = params
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeCodec(NewGobCodec(sconn))
+		close(served)
+	}()
+	clt := NewClient(cconn)
+	clt.SetMetrics(cltMetrics)
+	go clt.Run()
+
+	var reply int
+	clt.Call(context.Background(), "ok", 0, &reply)
+	clt.Call(context.Background(), "fail", 0, &reply)
+	// Gob passes a []byte on to the fallback.
+	clt.Call(context.Background(), "any", []byte("x"), new([]byte))
+	clt.Close()
+	<-served
+	<-clt.DisconnectNotify()
+
+	want := "[call any <nil> call fail failed call ok <nil> close open]"
+	if got := fmt.Sprint(cltMetrics.sorted()); got != want {
+		t.Fatalf("client: not expected: %s", got)
+	}
+	want = "[close handler _fallback <nil> handler fail failed handler ok <nil> " +
+		"inflight _fallback -1 inflight _fallback 1 inflight fail -1 inflight fail 1 inflight ok -1 inflight ok 1 open]"
+	if got := fmt.Sprint(srvMetrics.sorted()); got != want {
+		t.Fatalf("server: not expected: %s", got)
+	}
+	if got := replaced.sorted(); len(got) != 0 {
+		t.Fatalf("replaced metrics still called: %s", got)
+	}
+}
@@ -0,0 +1,230 @@
+// Package openrpc builds OpenRPC documents describing the methods registered
+// on a birpc.Server or birpc.Client, with JSON Schemas of their arguments and
+// replies as encoded by the jsonrpc codec.
+//
+//	doc := openrpc.New(openrpc.Info{Title: "calc", Version: "1.0.0"}, srv.Methods())
+//	json.NewEncoder(w).Encode(doc)
+//
+// Methods whose arguments are a slice are described by its items, since the
+// jsonrpc codec sends a slice as the params themselves: they have a single
+// optional param, standing for any number of params of the same schema.
+//
+// Streaming methods are left out, since they have no plain request/response form.
+package openrpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+// Version is the version of the OpenRPC specification the documents follow.
+const Version = "1.2.6"
+
+// Document is an OpenRPC document.
+type Document struct {
+	OpenRPC    string     `json:"openrpc"`
+	Info       Info       `json:"info"`
+	Methods    []Method   `json:"methods"`
+	Components Components `json:"components"`
+}
+
+// Info describes the service.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Method describes a method, called with its arguments as the only positional
+// param, or with the items of a slice as the params.
+type Method struct {
+	Name           string              `json:"name"`
+	ParamStructure string              `json:"paramStructure"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         ContentDescriptor   `json:"result"`
+}
+
+// ContentDescriptor describes the params and result of a method.
+type ContentDescriptor struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Components holds the schemas of the named struct types, referenced by the methods.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of JSON Schema needed to describe Go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// New returns the document describing methods, as returned by Server.Methods
+// or Client.Methods.
+func New(info Info, methods []birpc.Method) *Document {
+	g := &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+	doc := &Document{
+		OpenRPC: Version,
+		Info:    info,
+		Methods: make([]Method, 0, len(methods)),
+	}
+	for _, m := range methods {
+		if m.Upload || m.Stream {
+			continue
+		}
+		params := ContentDescriptor{
+			Name:     "args",
+			Required: true,
+			Schema:   g.schema(m.ArgType),
+		}
+		if isItems(m.ArgType) {
+			params = ContentDescriptor{
+				Name:        "items",
+				Description: "Any number of params, the items of the arguments.",
+				Schema:      g.schema(m.ArgType.Elem()),
+			}
+		}
+		doc.Methods = append(doc.Methods, Method{
+			Name:           m.Name,
+			ParamStructure: "by-position",
+			Params:         []ContentDescriptor{params},
+			Result: ContentDescriptor{
+				Name:   "reply",
+				Schema: g.schema(m.ReplyType),
+			},
+		})
+	}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// isItems reports whether arguments of type t are sent as the params array
+// by the jsonrpc codec, that is t is a slice encoded as a JSON array.
+func isItems(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeOfRawMessage    = reflect.TypeOf(json.RawMessage(nil))
+)
+
+type generator struct {
+	schemas map[string]*Schema      // component name -> schema
+	names   map[reflect.Type]string // struct type -> component name
+}
+
+// schema returns the schema of the JSON encoding of values of type t.
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeOfTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == typeOfRawMessage,
+		t.Implements(typeOfJSONMarshaler), reflect.PtrTo(t).Implements(typeOfJSONMarshaler):
+		return &Schema{} // any value
+	case t.Implements(typeOfTextMarshaler), reflect.PtrTo(t).Implements(typeOfTextMarshaler):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &Schema{} // interfaces and anything else
+}
+
+// component returns the name of the component schema of the struct type t,
+// adding it on first use.
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		// Same name in another package.
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+	}
+	g.names[t] = name
+	g.schemas[name] = nil // reserved, for recursive types
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields adds the properties encoding/json produces for the fields of t.
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(tag, ",string") {
+			s.Properties[name] = &Schema{Type: "string"}
+			continue
+		}
+		s.Properties[name] = g.schema(f.Type)
+	}
+}
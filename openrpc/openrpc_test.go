@@ -0,0 +1,51 @@
+package openrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+type Item struct {
+	ID      string `json:"id"`
+	Tags    []string
+	Data    []byte    `json:",omitempty"`
+	Created time.Time `json:"created"`
+	Parent  *Item     `json:"parent,omitempty"`
+	secret  int
+}
+
+func TestNew(t *testing.T) {
+	srv := birpc.NewServer()
+	srv.Handle("Items.Get", func(ctx context.Context, id string, reply *Item) error {
+		return nil
+	})
+	srv.Handle("Items.Count", func(ctx context.Context, filter map[string]int, reply *int) error {
+		return nil
+	})
+	srv.Handle("Items.Delete", func(ctx context.Context, ids []string, reply *int) error {
+		return nil
+	})
+	srv.Handle("Items.Upload", func(ctx context.Context, items <-chan Item, reply *int) error {
+		return nil
+	})
+
+	doc := New(Info{Title: "items", Version: "1.0.0"}, srv.Methods())
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"openrpc":"1.2.6","info":{"title":"items","version":"1.0.0"},"methods":[` +
+		`{"name":"Items.Count","paramStructure":"by-position","params":[{"name":"args","required":true,"schema":{"type":"object","additionalProperties":{"type":"integer"}}}],"result":{"name":"reply","schema":{"type":"integer"}}},` +
+		`{"name":"Items.Delete","paramStructure":"by-position","params":[{"name":"items","description":"Any number of params, the items of the arguments.","schema":{"type":"string"}}],"result":{"name":"reply","schema":{"type":"integer"}}},` +
+		`{"name":"Items.Get","paramStructure":"by-position","params":[{"name":"args","required":true,"schema":{"type":"string"}}],"result":{"name":"reply","schema":{"$ref":"#/components/schemas/Item"}}}],` +
+		`"components":{"schemas":{"Item":{"type":"object","properties":{` +
+		`"Data":{"type":"string","contentEncoding":"base64"},"Tags":{"type":"array","items":{"type":"string"}},` +
+		`"created":{"type":"string","format":"date-time"},"id":{"type":"string"},"parent":{"$ref":"#/components/schemas/Item"}}}}}}`
+	if string(got) != want {
+		t.Fatalf("not expected:\n%s", got)
+	}
+}
@@ -0,0 +1,93 @@
+// Package otelbirpc traces birpc calls and handlers with OpenTelemetry.
+//
+// The trace context travels in the header of the requests, so the handler of
+// a call, and the calls it makes back to the caller, are part of the trace of
+// the original call.
+//
+//	srv.SetTracer(otelbirpc.New())
+//	clt.SetTracer(otelbirpc.New())
+package otelbirpc
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer of this package.
+const instrumentationName = "github.com/cgrates/birpc/otelbirpc"
+
+var rpcSystem = attribute.String("rpc.system", "birpc")
+
+// Tracer implements birpc.Tracer with OpenTelemetry.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// Option configures a Tracer.
+type Option func(*Tracer)
+
+// WithTracerProvider creates the spans with tp instead of the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// WithPropagator carries the trace context with p instead of the global propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = p
+	}
+}
+
+// New returns a Tracer using the global tracer provider and propagator,
+// unless configured otherwise.
+func New(opts ...Option) *Tracer {
+	t := &Tracer{
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// StartCall implements birpc.Tracer, starting a client span.
+func (t *Tracer) StartCall(ctx context.Context, method string, header map[string]string) func(error) {
+	ctx, span := t.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(rpcSystem, attribute.String("rpc.method", method)))
+	t.propagator.Inject(ctx, propagation.MapCarrier(header))
+	return func(err error) {
+		end(span, err)
+	}
+}
+
+// StartHandler implements birpc.Tracer, starting a server span that is a
+// child of the span of the call, if the request carries its context.
+func (t *Tracer) StartHandler(ctx context.Context, method string, header map[string]string) (context.Context, func(error)) {
+	if header != nil {
+		ctx = t.propagator.Extract(ctx, propagation.MapCarrier(header))
+	}
+	ctx, span := t.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(rpcSystem, attribute.String("rpc.method", method)))
+	return ctx, func(err error) {
+		end(span, err)
+	}
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
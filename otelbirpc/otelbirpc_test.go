@@ -0,0 +1,84 @@
+package otelbirpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/cgrates/birpc"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := New(WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+
+	srv := birpc.NewServer()
+	srv.SetTracer(tracer)
+	srv.Handle("add", func(ctx context.Context, n int, reply *int) error {
+		// Call back the client within the trace of the call.
+		if err := birpc.ClientValueFromContext(ctx).Call(ctx, "double", n, reply); err != nil {
+			return err
+		}
+		*reply++
+		return nil
+	})
+	srv.Handle("fail", func(ctx context.Context, _ int, _ *int) error {
+		return errors.New("failed")
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(birpc.NewGobCodec(sconn))
+	clt := birpc.NewClient(cconn)
+	clt.SetTracer(tracer)
+	clt.Handle("double", func(ctx context.Context, n int, reply *int) error {
+		*reply = 2 * n
+		return nil
+	})
+	go clt.Run()
+	defer clt.Close()
+
+	ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+	var reply int
+	if err := clt.Call(ctx, "add", 2, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != 5 {
+		t.Fatalf("not expected: %d", reply)
+	}
+	clt.Call(ctx, "fail", 0, &reply)
+	root.End()
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name+"/"+s.SpanKind.String()] = s
+	}
+	if len(spans) != 7 {
+		t.Fatalf("expected 7 spans, got: %v", exporter.GetSpans().Snapshots())
+	}
+	// Every span is the child of the one before it.
+	chain := []string{"root/internal", "add/client", "add/server", "double/client", "double/server"}
+	for i := 1; i < len(chain); i++ {
+		parent, child := spans[chain[i-1]], spans[chain[i]]
+		if child.Parent.SpanID() != parent.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of %s", chain[i], chain[i-1])
+		}
+		if child.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+			t.Errorf("%s is not in the trace of %s", chain[i], chain[i-1])
+		}
+	}
+	for _, name := range []string{"fail/client", "fail/server"} {
+		if s := spans[name]; s.Status.Code != codes.Error || s.Status.Description != "failed" {
+			t.Errorf("%s: not expected status: %+v", name, s.Status)
+		}
+	}
+	if spans["add/client"].SpanKind != trace.SpanKindClient {
+		t.Error("call span is not a client span")
+	}
+}
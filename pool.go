@@ -0,0 +1,242 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BalancePolicy selects the connection of a Pool that handles a call.
+type BalancePolicy int
+
+const (
+	// RoundRobin uses the connections in turn.
+	RoundRobin BalancePolicy = iota
+	// LeastPending uses the connection with the fewest calls waiting for a reply.
+	LeastPending
+	// Random uses a connection picked at random.
+	Random
+)
+
+// ErrNoConnection is returned by a Pool none of whose addresses can be reached.
+var ErrNoConnection = errors.New("birpc: no connection available")
+
+// redialDelay is how long a Pool waits before dialing an address again after it failed.
+const redialDelay = time.Second
+
+// PoolDialFunc connects to addr, giving up when ctx is done. The returned
+// Client must not be running yet, the Pool runs it.
+type PoolDialFunc func(ctx context.Context, addr string) (*Client, error)
+
+// Pool balances calls over Clients connected to a list of addresses,
+// such as the replicas of a service.
+// Connections are dialed when first needed, and dropped when they go away,
+// to be dialed again on a later call.
+type Pool struct {
+	dial   PoolDialFunc
+	policy BalancePolicy
+
+	mu      sync.Mutex
+	addrs   []string
+	clients []*Client   // by address index, nil if not connected
+	failed  []time.Time // last failed dial, by address index
+	next    int         // address index round robin starts from
+	rand    *rand.Rand
+	closed  bool
+}
+
+// NewPool returns a Pool balancing calls over addrs with policy.
+// If dial is nil, the pool connects over TCP with the gob codec.
+func NewPool(addrs []string, dial PoolDialFunc, policy BalancePolicy) *Pool {
+	if dial == nil {
+		dial = func(ctx context.Context, addr string) (*Client, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			return NewClient(conn), nil
+		}
+	}
+	return &Pool{
+		dial:    dial,
+		policy:  policy,
+		addrs:   addrs,
+		clients: make([]*Client, len(addrs)),
+		failed:  make([]time.Time, len(addrs)),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Call invokes the named function on a connection picked by the pool policy,
+// waits for it to complete, and returns its error status.
+func (p *Pool) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	c, err := p.pick(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Call(ctx, method, args, reply)
+}
+
+// Go invokes the function asynchronously on a connection picked by the pool policy,
+// see Client.Go. If no connection is available, the call is done with the error.
+func (p *Pool) Go(method string, args interface{}, reply interface{}, done chan *Call) *Call {
+	c, err := p.pick(context.Background())
+	if err != nil {
+		if done == nil {
+			done = make(chan *Call, 1)
+		}
+		call := &Call{Method: method, Args: args, Reply: reply, Error: err, Done: done}
+		call.done()
+		return call
+	}
+	return c.Go(method, args, reply, done)
+}
+
+// Notify sends a notification on a connection picked by the pool policy.
+func (p *Pool) Notify(method string, args interface{}) error {
+	c, err := p.pick(context.Background())
+	if err != nil {
+		return err
+	}
+	return c.Notify(method, args)
+}
+
+// Close closes all the connections of the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrShutdown
+	}
+	p.closed = true
+	clients := p.clients
+	p.clients = make([]*Client, len(p.addrs))
+	p.mu.Unlock()
+	for _, c := range clients {
+		if c != nil {
+			c.Close()
+		}
+	}
+	return nil
+}
+
+// pick returns the connection for the next call,
+// dialing the preferred address if it is not connected, until ctx is done.
+func (p *Pool) pick(ctx context.Context) (*Client, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrShutdown
+	}
+	order := p.order()
+	p.mu.Unlock()
+
+	err := ErrNoConnection
+	for _, i := range order {
+		c, cerr := p.connect(ctx, i)
+		if cerr == nil {
+			if p.policy == RoundRobin {
+				// Continue after the address used, skipping unreachable ones.
+				p.mu.Lock()
+				p.next = (i + 1) % len(p.addrs)
+				p.mu.Unlock()
+			}
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if cerr != ErrNoConnection {
+			err = cerr // report why dialing failed
+		}
+	}
+	return nil, err
+}
+
+// order returns the address indexes in the order the policy prefers them.
+// It must be called with p.mu held.
+func (p *Pool) order() []int {
+	n := len(p.addrs)
+	order := make([]int, n)
+	switch p.policy {
+	case Random:
+		copy(order, p.rand.Perm(n))
+	case LeastPending:
+		pending := make([]int, n)
+		for i, c := range p.clients {
+			order[i] = i
+			if c != nil {
+				pending[i] = c.numPending()
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return pending[order[a]] < pending[order[b]]
+		})
+	default:
+		for i := range order {
+			order[i] = (p.next + i) % n
+		}
+	}
+	return order
+}
+
+// connect returns the connection to the address with index i.
+// If there is none, it dials the address, unless it failed recently,
+// and runs the new connection.
+func (p *Pool) connect(ctx context.Context, i int) (*Client, error) {
+	p.mu.Lock()
+	if c := p.clients[i]; c != nil || p.closed {
+		p.mu.Unlock()
+		if c == nil {
+			return nil, ErrShutdown
+		}
+		return c, nil
+	}
+	if time.Since(p.failed[i]) < redialDelay {
+		p.mu.Unlock()
+		return nil, ErrNoConnection
+	}
+	p.mu.Unlock()
+
+	c, err := p.dial(ctx, p.addrs[i])
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			// Not given up by the caller, the address is unreachable.
+			p.failed[i] = time.Now()
+		}
+		return nil, err
+	}
+	if p.closed {
+		c.Close()
+		return nil, ErrShutdown
+	}
+	if prev := p.clients[i]; prev != nil {
+		// Dialed concurrently by another call.
+		c.Close()
+		return prev, nil
+	}
+	p.clients[i] = c
+	go c.Run()
+	go func() {
+		<-c.DisconnectNotify()
+		p.mu.Lock()
+		if p.clients[i] == c {
+			p.clients[i] = nil
+		}
+		p.mu.Unlock()
+	}()
+	return c, nil
+}
+
+// numPending returns the number of calls waiting for a reply.
+func (c *Client) numPending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pending)
+}
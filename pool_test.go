@@ -0,0 +1,123 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		conns   = make(map[string]net.Conn) // server side, by address
+		release = make(chan struct{})
+	)
+	srv := NewServer()
+	srv.Handle("addr", func(ctx context.Context, block bool, reply *string) error {
+		if block {
+			<-release
+		}
+		addr, _ := ClientValueFromContext(ctx).State.Get("addr")
+		*reply = addr.(string)
+		return nil
+	})
+	dial := func(ctx context.Context, addr string) (*Client, error) {
+		switch addr {
+		case "down":
+			return nil, errors.New("connection refused")
+		case "unreachable":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		cconn, sconn := net.Pipe()
+		mu.Lock()
+		conns[addr] = sconn
+		mu.Unlock()
+		go srv.ServeCodecWithState(NewGobCodec(sconn), stateWith("addr", addr))
+		return NewClient(cconn), nil
+	}
+	call := func(p *Pool) string {
+		var addr string
+		if err := p.Call(context.Background(), "addr", false, &addr); err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+
+	p := NewPool([]string{"a", "down", "b"}, dial, RoundRobin)
+	got := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		got[call(p)]++
+	}
+	if got["a"] != 3 || got["b"] != 3 {
+		t.Fatalf("not balanced: %v", got)
+	}
+
+	// A connection that goes away is dropped and dialed again.
+	p.mu.Lock()
+	a := p.clients[0]
+	p.mu.Unlock()
+	mu.Lock()
+	conns["a"].Close()
+	mu.Unlock()
+	<-a.DisconnectNotify()
+	deadline := time.Now().Add(time.Second)
+	for dropped := false; !dropped; {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not dropped")
+		}
+		time.Sleep(time.Millisecond)
+		p.mu.Lock()
+		dropped = p.clients[0] != a
+		p.mu.Unlock()
+	}
+	got = make(map[string]int)
+	for i := 0; i < 4; i++ {
+		got[call(p)]++
+	}
+	if got["a"] != 2 {
+		t.Fatalf("not redialed: %v", got)
+	}
+	p.Close()
+	if err := p.Call(context.Background(), "addr", false, new(string)); err != ErrShutdown {
+		t.Fatalf("expected shutdown, got: %v", err)
+	}
+
+	p = NewPool([]string{"a", "b"}, dial, LeastPending)
+	defer p.Close()
+	blocked := p.Go("addr", true, new(string), nil)
+	for i := 0; i < 3; i++ {
+		if addr := call(p); addr == "b" {
+			// a was picked for the blocked call, being idle first.
+			continue
+		}
+		t.Fatal("call sent to the busy connection")
+	}
+	close(release)
+	if call := <-blocked.Done; call.Error != nil || *call.Reply.(*string) != "a" {
+		t.Fatalf("not expected: %v, %v", call.Error, *call.Reply.(*string))
+	}
+
+	if err := NewPool([]string{"down"}, dial, Random).Notify("addr", false); err == nil || err.Error() != "connection refused" {
+		t.Fatalf("expected dial error, got: %v", err)
+	}
+
+	// Dialing gives up with the call.
+	p = NewPool([]string{"unreachable", "a"}, dial, RoundRobin)
+	defer p.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Call(ctx, "addr", false, new(string)); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got: %v", err)
+	}
+	// The address is not taken as unreachable for the next call.
+	p.mu.Lock()
+	failed := p.failed[0]
+	p.mu.Unlock()
+	if !failed.IsZero() {
+		t.Fatal("address marked as failed")
+	}
+}
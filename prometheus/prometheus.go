@@ -0,0 +1,179 @@
+// Package prometheus records the metrics of birpc clients and servers with
+// the Prometheus client library.
+//
+//	m := prometheus.New(prom.DefaultRegisterer, "myapp")
+//	srv.SetMetrics(m)
+//
+// The state of the circuits of a birpc.Breaker is recorded by passing
+// BreakerStateChange as the OnStateChange hook of its configuration.
+//
+// Method labels are bounded, as method names may be chosen by the peer:
+// requests handled by a fallback are labelled birpc.FallbackMethod, and
+// methods seen after the first MaxMethods are labelled OtherMethod.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cgrates/birpc"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements birpc.Metrics with Prometheus collectors.
+type Metrics struct {
+	calls           *prom.CounterVec
+	callDuration    *prom.HistogramVec
+	handled         *prom.CounterVec
+	handlerDuration *prom.HistogramVec
+	inFlight        *prom.GaugeVec
+	conns           prom.Gauge
+	connects        prom.Counter
+	disconnects     prom.Counter
+	breakerState    *prom.GaugeVec
+
+	mu      sync.Mutex
+	methods map[string]struct{} // methods with their own label
+}
+
+// MaxMethods is the number of methods labelled with their name.
+const MaxMethods = 256
+
+// OtherMethod is the label of the methods past MaxMethods.
+const OtherMethod = "other"
+
+// New returns Metrics whose collectors are registered with reg,
+// named with the given namespace. It panics if registration fails.
+func New(reg prom.Registerer, namespace string) *Metrics {
+	m := &Metrics{
+		calls: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "calls_total",
+			Help:      "Outgoing calls by method and status.",
+		}, []string{"method", "status"}),
+		callDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "call_duration_seconds",
+			Help:      "Latency of outgoing calls by method and status.",
+			Buckets:   prom.DefBuckets,
+		}, []string{"method", "status"}),
+		handled: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "handled_total",
+			Help:      "Handled requests by method and status.",
+		}, []string{"method", "status"}),
+		handlerDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "handler_duration_seconds",
+			Help:      "Execution time of handlers by method.",
+			Buckets:   prom.DefBuckets,
+		}, []string{"method"}),
+		inFlight: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "in_flight_requests",
+			Help:      "Requests being handled by method.",
+		}, []string{"method"}),
+		conns: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "connections",
+			Help:      "Open connections.",
+		}),
+		connects: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "connects_total",
+			Help:      "Connections opened.",
+		}),
+		disconnects: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "disconnects_total",
+			Help:      "Connections closed.",
+		}),
+		breakerState: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "birpc",
+			Name:      "breaker_state",
+			Help:      "State of the circuit breaker by method: 0 closed, 1 open, 2 half-open.",
+		}, []string{"method"}),
+	}
+	m.methods = make(map[string]struct{})
+	reg.MustRegister(m.calls, m.callDuration, m.handled, m.handlerDuration,
+		m.inFlight, m.conns, m.connects, m.disconnects, m.breakerState)
+	return m
+}
+
+// Status returns the status label of a call or handler that returned err:
+// "ok", "canceled", "deadline_exceeded", "unavailable" for connection
+// errors, or "error".
+func Status(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case birpc.IsConnectionError(err):
+		return "unavailable"
+	}
+	return "error"
+}
+
+// label returns the label of method.
+func (m *Metrics) label(method string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.methods[method]; !ok {
+		if len(m.methods) >= MaxMethods {
+			return OtherMethod
+		}
+		m.methods[method] = struct{}{}
+	}
+	return method
+}
+
+// CallDone implements birpc.Metrics.
+func (m *Metrics) CallDone(method string, err error, elapsed time.Duration) {
+	method, status := m.label(method), Status(err)
+	m.calls.WithLabelValues(method, status).Inc()
+	m.callDuration.WithLabelValues(method, status).Observe(elapsed.Seconds())
+}
+
+// HandlerDone implements birpc.Metrics.
+func (m *Metrics) HandlerDone(method string, err error, elapsed time.Duration) {
+	method = m.label(method)
+	m.handled.WithLabelValues(method, Status(err)).Inc()
+	m.handlerDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+}
+
+// InFlight implements birpc.Metrics.
+func (m *Metrics) InFlight(method string, delta int) {
+	m.inFlight.WithLabelValues(m.label(method)).Add(float64(delta))
+}
+
+// ConnOpened implements birpc.Metrics.
+func (m *Metrics) ConnOpened() {
+	m.conns.Inc()
+	m.connects.Inc()
+}
+
+// ConnClosed implements birpc.Metrics.
+func (m *Metrics) ConnClosed() {
+	m.conns.Dec()
+	m.disconnects.Inc()
+}
+
+// BreakerStateChange records the state of the circuit of method.
+// It is meant as birpc.BreakerConfig.OnStateChange.
+func (m *Metrics) BreakerStateChange(method string, from, to birpc.BreakerState) {
+	m.breakerState.WithLabelValues(m.label(method)).Set(float64(to))
+}
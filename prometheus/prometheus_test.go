@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/cgrates/birpc"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := New(reg, "test")
+
+	srv := birpc.NewServer()
+	srv.SetMetrics(m)
+	srv.Handle("echo", func(ctx context.Context, n int, reply *int) error {
+		if n < 0 {
+			return errors.New("negative")
+		}
+		*reply = n
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(birpc.NewGobCodec(sconn))
+	clt := birpc.NewClient(cconn)
+	clt.SetMetrics(m)
+	go clt.Run()
+	defer clt.Close()
+
+	var reply int
+	for _, n := range []int{1, 2, -1} {
+		clt.Call(context.Background(), "echo", n, &reply)
+	}
+
+	for _, tc := range []struct {
+		c    prom.Collector
+		want float64
+	}{
+		{m.calls.WithLabelValues("echo", "ok"), 2},
+		{m.calls.WithLabelValues("echo", "error"), 1},
+		{m.handled.WithLabelValues("echo", "ok"), 2},
+		{m.handled.WithLabelValues("echo", "error"), 1},
+		{m.inFlight.WithLabelValues("echo"), 0},
+		{m.conns, 2}, // both ends of the connection
+		{m.connects, 2},
+	} {
+		if got := testutil.ToFloat64(tc.c); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.c, got, tc.want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.handlerDuration); n != 1 {
+		t.Errorf("expected one handler duration series, got %d", n)
+	}
+
+	b := birpc.NewBreaker(clt, birpc.BreakerConfig{
+		MinCalls:      1,
+		IsFailure:     func(error) bool { return true },
+		OnStateChange: m.BreakerStateChange,
+	})
+	b.Call(context.Background(), "echo", -1, &reply)
+	if got := testutil.ToFloat64(m.breakerState.WithLabelValues("echo")); got != float64(birpc.BreakerOpen) {
+		t.Errorf("breaker state: got %v, want open", got)
+	}
+
+	// Methods past the limit share a label.
+	for i := 0; i < MaxMethods; i++ {
+		m.CallDone(fmt.Sprint("method", i), nil, 0)
+	}
+	if got := testutil.ToFloat64(m.calls.WithLabelValues(OtherMethod, "ok")); got != 1 {
+		t.Errorf("other methods: got %v, want 1", got)
+	}
+}
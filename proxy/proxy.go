@@ -0,0 +1,177 @@
+// Package proxy forwards calls between birpc connections without decoding them.
+//
+// A Proxy takes over the unknown methods of a birpc.Server and routes them by
+// name to upstream backends. Every edge connection gets its own connection to
+// each backend it calls, so calls the backend makes on that connection are
+// routed back to the edge that caused it to be opened.
+//
+// Params and replies are passed along as birpc.RawMessage, which keeps them
+// intact with the jsonrpc codec. The proxy is meant for jsonrpc connections:
+// gob cannot pass params along undecoded, other than a []byte, so over gob
+// such calls are answered with an error. Streaming calls are not forwarded.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+
+	"github.com/cgrates/birpc"
+)
+
+// ErrNoRoute is returned for methods that match no route.
+var ErrNoRoute = errors.New("proxy: no route for method")
+
+// DialFunc opens a connection to an upstream backend and returns its codec.
+type DialFunc func(ctx context.Context) (birpc.Codec, error)
+
+type route struct {
+	pattern string
+	dial    DialFunc
+}
+
+// Proxy routes the calls received by a server to upstream backends.
+type Proxy struct {
+	mu     sync.RWMutex
+	routes []*route
+	edges  map[*birpc.Client]*edge
+}
+
+// edge holds the upstream connections opened for an edge connection.
+type edge struct {
+	c         *birpc.Client
+	mu        sync.Mutex
+	upstreams map[*route]*birpc.Client
+	closed    bool
+}
+
+// New returns a Proxy forwarding the calls srv has no handler for.
+// Handlers registered on srv keep answering their methods.
+func New(srv *birpc.Server) *Proxy {
+	p := &Proxy{edges: make(map[*birpc.Client]*edge)}
+	srv.SetFallback(p.forward)
+	return p
+}
+
+// Route forwards the methods matching pattern, in the syntax of path.Match,
+// to the backends dialed by dial. Routes are tried in the order they were added.
+// A pattern like "Users.*" routes all the methods of a service.
+func (p *Proxy) Route(pattern string, dial DialFunc) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic("proxy: malformed route pattern " + pattern)
+	}
+	p.mu.Lock()
+	p.routes = append(p.routes, &route{pattern: pattern, dial: dial})
+	p.mu.Unlock()
+}
+
+func (p *Proxy) match(method string) *route {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, r := range p.routes {
+		if ok, _ := path.Match(r.pattern, method); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// forward is the fallback handler of the server.
+func (p *Proxy) forward(ctx context.Context, method string, params birpc.RawMessage, reply *birpc.RawMessage) error {
+	r := p.match(method)
+	if r == nil {
+		return ErrNoRoute
+	}
+	upstream, err := p.edge(birpc.ClientValueFromContext(ctx)).upstream(ctx, r)
+	if err != nil {
+		return err
+	}
+	if birpc.IsNotification(ctx) {
+		return upstream.Notify(method, params)
+	}
+	return upstream.Call(ctx, method, params, reply)
+}
+
+// edge returns the state of the edge connection c,
+// creating it on its first call.
+func (p *Proxy) edge(c *birpc.Client) *edge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.edges[c]
+	if !ok {
+		e = &edge{c: c, upstreams: make(map[*route]*birpc.Client)}
+		p.edges[c] = e
+		go func() {
+			<-c.DisconnectNotify()
+			p.mu.Lock()
+			delete(p.edges, c)
+			p.mu.Unlock()
+			e.close()
+		}()
+	}
+	return e
+}
+
+// upstream returns the connection of the edge to the backends of r,
+// dialing it if there is none.
+func (e *edge) upstream(ctx context.Context, r *route) (*birpc.Client, error) {
+	e.mu.Lock()
+	u, ok := e.upstreams[r]
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return nil, birpc.ErrShutdown
+	}
+	if ok {
+		return u, nil
+	}
+	// Dial without the lock, so that the other calls of the edge go on meanwhile.
+	codec, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		codec.Close()
+		return nil, birpc.ErrShutdown
+	}
+	if u, ok := e.upstreams[r]; ok {
+		// Dialed concurrently by another call.
+		codec.Close()
+		return u, nil
+	}
+	u = birpc.NewClientWithCodec(codec)
+	u.SetFallback(e.reverse)
+	e.upstreams[r] = u
+	go func() {
+		u.Run()
+		// Dial again on the next call.
+		e.mu.Lock()
+		if e.upstreams[r] == u {
+			delete(e.upstreams, r)
+		}
+		e.mu.Unlock()
+	}()
+	return u, nil
+}
+
+// reverse forwards the calls of a backend to the edge.
+func (e *edge) reverse(ctx context.Context, method string, params birpc.RawMessage, reply *birpc.RawMessage) error {
+	if birpc.IsNotification(ctx) {
+		return e.c.Notify(method, params)
+	}
+	return e.c.Call(ctx, method, params, reply)
+}
+
+func (e *edge) close() {
+	e.mu.Lock()
+	e.closed = true
+	upstreams := e.upstreams
+	e.upstreams = nil
+	e.mu.Unlock()
+	for _, u := range upstreams {
+		u.Close()
+	}
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgrates/birpc"
+	"github.com/cgrates/birpc/jsonrpc"
+)
+
+type User struct {
+	ID   int
+	Name string
+}
+
+func TestProxy(t *testing.T) {
+	logged := make(chan string, 1)
+	backend := birpc.NewServer()
+	backend.Handle("Users.Get", func(ctx context.Context, id int, reply *User) error {
+		// Ask the edge that made the call who it is.
+		var name string
+		if err := birpc.ClientValueFromContext(ctx).Call(ctx, "Edge.Name", id, &name); err != nil {
+			return err
+		}
+		*reply = User{ID: id, Name: name}
+		return nil
+	})
+	backend.Handle("Users.Log", func(ctx context.Context, msg string, _ *bool) error {
+		logged <- msg
+		return nil
+	})
+
+	srv := birpc.NewServer()
+	srv.Handle("Hub.Ping", func(ctx context.Context, _ int, reply *string) error {
+		*reply = "pong"
+		return nil
+	})
+	p := New(srv)
+	p.Route("Users.*", func(ctx context.Context) (birpc.Codec, error) {
+		cconn, sconn := net.Pipe()
+		go backend.ServeCodec(jsonrpc.NewJSONCodec(sconn))
+		return jsonrpc.NewJSONCodec(cconn), nil
+	})
+
+	dial := func(name string) *birpc.Client {
+		cconn, sconn := net.Pipe()
+		go srv.ServeCodec(jsonrpc.NewJSONCodec(sconn))
+		clt := birpc.NewClientWithCodec(jsonrpc.NewJSONCodec(cconn))
+		clt.Handle("Edge.Name", func(ctx context.Context, _ int, reply *string) error {
+			*reply = name
+			return nil
+		})
+		go clt.Run()
+		return clt
+	}
+	for _, name := range []string{"alice", "bob"} {
+		clt := dial(name)
+		defer clt.Close()
+		var user User
+		if err := clt.Call(context.Background(), "Users.Get", 7, &user); err != nil {
+			t.Fatal(err)
+		}
+		if user != (User{ID: 7, Name: name}) {
+			t.Fatalf("not expected: %+v", user)
+		}
+	}
+
+	clt := dial("carol")
+	defer clt.Close()
+	var pong string
+	if err := clt.Call(context.Background(), "Hub.Ping", 0, &pong); err != nil || pong != "pong" {
+		t.Fatalf("not expected: %q, %v", pong, err)
+	}
+	if err := clt.Call(context.Background(), "Orders.Get", 1, &pong); err == nil || err.Error() != ErrNoRoute.Error() {
+		t.Fatalf("expected no route, got: %v", err)
+	}
+	if err := clt.Notify("Users.Log", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-logged; msg != "hello" {
+		t.Fatalf("not expected: %q", msg)
+	}
+}
+
+func TestProxyGob(t *testing.T) {
+	srv := birpc.NewServer()
+	srv.Handle("Hub.Ping", func(ctx context.Context, _ int, reply *string) error {
+		*reply = "pong"
+		return nil
+	})
+	New(srv).Route("Users.*", func(ctx context.Context) (birpc.Codec, error) {
+		t.Error("params that gob cannot pass along must not be forwarded")
+		return nil, ErrNoRoute
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(birpc.NewGobCodec(sconn))
+	clt := birpc.NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	var user User
+	if err := clt.Call(context.Background(), "Users.Get", 7, &user); err == nil {
+		t.Fatal("expected error over gob")
+	}
+	var pong string
+	if err := clt.Call(context.Background(), "Hub.Ping", 0, &pong); err != nil || pong != "pong" {
+		t.Fatalf("connection not usable after the failed call: %q, %v", pong, err)
+	}
+}
+
+func TestProxySlowDial(t *testing.T) {
+	backend := birpc.NewServer()
+	backend.Handle("Users.Get", func(ctx context.Context, id int, reply *User) error {
+		*reply = User{ID: id}
+		return nil
+	})
+	backend.Handle("Orders.Get", func(ctx context.Context, id int, reply *int) error {
+		*reply = id
+		return nil
+	})
+	connect := func() birpc.Codec {
+		cconn, sconn := net.Pipe()
+		go backend.ServeCodec(jsonrpc.NewJSONCodec(sconn))
+		return jsonrpc.NewJSONCodec(cconn)
+	}
+
+	srv := birpc.NewServer()
+	p := New(srv)
+	dialing, release := make(chan struct{}), make(chan struct{})
+	p.Route("Orders.*", func(ctx context.Context) (birpc.Codec, error) {
+		close(dialing)
+		<-release
+		return connect(), nil
+	})
+	p.Route("Users.*", func(ctx context.Context) (birpc.Codec, error) {
+		return connect(), nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(jsonrpc.NewJSONCodec(sconn))
+	clt := birpc.NewClientWithCodec(jsonrpc.NewJSONCodec(cconn))
+	go clt.Run()
+	defer clt.Close()
+
+	order := clt.Go("Orders.Get", 3, new(int), nil)
+	<-dialing
+	// The other routes of the edge are not held back by the dial.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var user User
+	if err := clt.Call(ctx, "Users.Get", 7, &user); err != nil || user.ID != 7 {
+		t.Fatalf("not expected: %+v, %v", user, err)
+	}
+	close(release)
+	select {
+	case call := <-order.Done:
+		if call.Error != nil || *call.Reply.(*int) != 3 {
+			t.Fatalf("not expected: %v, %v", *call.Reply.(*int), call.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("slow dial call not answered")
+	}
+}
@@ -0,0 +1,226 @@
+// Package pubsub implements publish/subscribe topics on top of birpc connections.
+//
+// A Broker registers the subscription methods on a birpc.Server. Clients
+// subscribe to topics with Subscribe and receive every payload published on
+// a topic as a notification whose method name is the topic, handled like any
+// other method:
+//
+//	clt.Handle("sessions.created", func(ctx context.Context, ev *Event, _ *struct{}) error {
+//		...
+//	})
+//	err := pubsub.Subscribe(ctx, clt, "sessions.created")
+//
+// Subscriptions last until they are removed with Unsubscribe or the
+// connection goes away. Every subscriber has a queue of notifications waiting
+// to be sent, so that a slow one does not hold back the others. Subscribers
+// whose queue is full are disconnected.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/cgrates/birpc"
+)
+
+// Methods registered by Broker.Register.
+const (
+	SubscribeMethod   = "PubSub.Subscribe"
+	UnsubscribeMethod = "PubSub.Unsubscribe"
+)
+
+// DefaultQueueSize is the number of notifications that may wait to be sent
+// to a subscriber, unless set with Broker.SetQueueSize.
+const DefaultQueueSize = 256
+
+// Broker keeps the subscriptions of the connected clients
+// and publishes payloads to them.
+type Broker struct {
+	mu        sync.RWMutex
+	topics    map[string]map[*birpc.Client]struct{} // subscribers by topic
+	clients   map[*birpc.Client]*subscriber
+	queueSize int
+}
+
+// subscriber is a client subscribed to topics.
+type subscriber struct {
+	topics map[string]struct{}
+	queue  chan notification // waiting to be sent
+}
+
+// notification is a payload published on a topic.
+type notification struct {
+	topic   string
+	payload interface{}
+}
+
+// NewBroker returns a Broker without subscriptions.
+func NewBroker() *Broker {
+	return &Broker{
+		topics:    make(map[string]map[*birpc.Client]struct{}),
+		clients:   make(map[*birpc.Client]*subscriber),
+		queueSize: DefaultQueueSize,
+	}
+}
+
+// SetQueueSize sets the number of notifications that may wait to be sent to
+// a subscriber before it is disconnected. It applies to clients subscribing
+// afterwards.
+func (b *Broker) SetQueueSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	b.mu.Lock()
+	b.queueSize = n
+	b.mu.Unlock()
+}
+
+// Register adds the subscription methods to r, usually a birpc.Server.
+func (b *Broker) Register(r birpc.Registry) {
+	r.Handle(SubscribeMethod, b.handleSubscribe)
+	r.Handle(UnsubscribeMethod, b.handleUnsubscribe)
+}
+
+func (b *Broker) handleSubscribe(ctx context.Context, topic string, reply *bool) error {
+	c := birpc.ClientValueFromContext(ctx)
+	if c == nil {
+		return errors.New("pubsub: no client in context")
+	}
+	if topic == "" {
+		return errors.New("pubsub: empty topic")
+	}
+	*reply = b.Subscribe(c, topic)
+	return nil
+}
+
+func (b *Broker) handleUnsubscribe(ctx context.Context, topic string, reply *bool) error {
+	c := birpc.ClientValueFromContext(ctx)
+	if c == nil {
+		return errors.New("pubsub: no client in context")
+	}
+	*reply = b.Unsubscribe(c, topic)
+	return nil
+}
+
+// Subscribe subscribes c to topic and reports whether it was not subscribed already.
+// The subscription is removed once the connection of c goes away.
+func (b *Broker) Subscribe(c *birpc.Client, topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-c.DisconnectNotify():
+		return false
+	default:
+	}
+	sub, ok := b.clients[c]
+	if !ok {
+		sub = &subscriber{
+			topics: make(map[string]struct{}),
+			queue:  make(chan notification, b.queueSize),
+		}
+		b.clients[c] = sub
+		go b.send(c, sub)
+	}
+	if _, ok = sub.topics[topic]; ok {
+		return false
+	}
+	sub.topics[topic] = struct{}{}
+	subs := b.topics[topic]
+	if subs == nil {
+		subs = make(map[*birpc.Client]struct{})
+		b.topics[topic] = subs
+	}
+	subs[c] = struct{}{}
+	return true
+}
+
+// Unsubscribe removes the subscription of c to topic and reports whether there was one.
+func (b *Broker) Unsubscribe(c *birpc.Client, topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := b.clients[c]
+	if sub == nil {
+		return false
+	}
+	if _, ok := sub.topics[topic]; !ok {
+		return false
+	}
+	delete(sub.topics, topic)
+	b.removeSubscriber(topic, c)
+	return true
+}
+
+// send sends the notifications queued for c until its connection goes away,
+// then removes its subscriptions.
+func (b *Broker) send(c *birpc.Client, sub *subscriber) {
+	for {
+		select {
+		case n := <-sub.queue:
+			// A payload failing to be sent is dropped,
+			// a broken connection goes away on its own.
+			c.Notify(n.topic, n.payload)
+		case <-c.DisconnectNotify():
+			b.mu.Lock()
+			for topic := range sub.topics {
+				b.removeSubscriber(topic, c)
+			}
+			delete(b.clients, c)
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (b *Broker) removeSubscriber(topic string, c *birpc.Client) {
+	subs := b.topics[topic]
+	delete(subs, c)
+	if len(subs) == 0 {
+		delete(b.topics, topic)
+	}
+}
+
+// Subscribers returns the number of clients subscribed to topic.
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic])
+}
+
+// Publish queues payload to be sent as a notification named topic to every
+// subscriber of topic and returns the number of subscribers it was queued for.
+// Payload must not be modified afterwards. Subscribers whose queue is full are
+// disconnected instead; they are removed once their connection goes away.
+func (b *Broker) Publish(topic string, payload interface{}) int {
+	n := notification{topic: topic, payload: payload}
+	var slow []*birpc.Client
+	queued := 0
+	b.mu.RLock()
+	for c := range b.topics[topic] {
+		select {
+		case b.clients[c].queue <- n:
+			queued++
+		default:
+			slow = append(slow, c)
+		}
+	}
+	b.mu.RUnlock()
+	for _, c := range slow {
+		c.Close()
+	}
+	return queued
+}
+
+// Subscribe asks the broker at the other end of c to send it the payloads
+// published on topic. They arrive as notifications named topic, so c needs
+// a handler for that method.
+func Subscribe(ctx context.Context, c *birpc.Client, topic string) error {
+	var ok bool
+	return c.Call(ctx, SubscribeMethod, topic, &ok)
+}
+
+// Unsubscribe cancels a subscription made with Subscribe.
+func Unsubscribe(ctx context.Context, c *birpc.Client, topic string) error {
+	var ok bool
+	return c.Call(ctx, UnsubscribeMethod, topic, &ok)
+}
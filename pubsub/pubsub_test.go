@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+func TestPubSub(t *testing.T) {
+	srv := birpc.NewServer()
+	broker := NewBroker()
+	broker.Register(srv)
+
+	connect := func() (*birpc.Client, chan string) {
+		cconn, sconn := net.Pipe()
+		go srv.ServeConn(sconn)
+		clt := birpc.NewClient(cconn)
+		news := make(chan string, 1)
+		clt.Handle("news", func(ctx context.Context, headline string, _ *bool) error {
+			news <- headline
+			return nil
+		})
+		go clt.Run()
+		if err := Subscribe(context.Background(), clt, "news"); err != nil {
+			t.Fatal(err)
+		}
+		return clt, news
+	}
+	clt1, news1 := connect()
+	clt2, news2 := connect()
+	defer clt2.Close()
+
+	if n := broker.Publish("news", "hello"); n != 2 {
+		t.Fatalf("published to %d subscribers", n)
+	}
+	for _, news := range []chan string{news1, news2} {
+		select {
+		case h := <-news:
+			if h != "hello" {
+				t.Fatalf("not expected: %s", h)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not get notification")
+		}
+	}
+	if n := broker.Publish("weather", "rain"); n != 0 {
+		t.Fatalf("published to %d subscribers", n)
+	}
+
+	// Subscriptions go away with the connection.
+	clt1.Close()
+	deadline := time.Now().Add(time.Second)
+	for broker.Subscribers("news") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not removed on disconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := Unsubscribe(context.Background(), clt2, "news"); err != nil {
+		t.Fatal(err)
+	}
+	if n := broker.Subscribers("news"); n != 0 {
+		t.Fatalf("%d subscribers left", n)
+	}
+}
+
+func TestPubSubSlowSubscriber(t *testing.T) {
+	srv := birpc.NewServer()
+	broker := NewBroker()
+	broker.SetQueueSize(2)
+	broker.Register(srv)
+
+	connect := func(blocking bool, news chan string) *birpc.Client {
+		cconn, sconn := net.Pipe()
+		go srv.ServeConn(sconn)
+		clt := birpc.NewClient(cconn)
+		clt.SetBlocking(blocking)
+		clt.Handle("news", func(ctx context.Context, headline string, _ *bool) error {
+			news <- headline
+			return nil
+		})
+		go clt.Run()
+		if err := Subscribe(context.Background(), clt, "news"); err != nil {
+			t.Fatal(err)
+		}
+		return clt
+	}
+	// The slow subscriber stops reading after its first notification.
+	slowNews := make(chan string)
+	slow := connect(true, slowNews)
+	defer slow.Close()
+	const n = 10
+	fastNews := make(chan string, n)
+	fast := connect(false, fastNews)
+	defer fast.Close()
+
+	for i := 0; i < n; i++ {
+		if queued := broker.Publish("news", "hello"); queued < 1 {
+			t.Fatalf("notification %d queued for %d subscribers", i, queued)
+		}
+		select {
+		case <-fastNews:
+		case <-time.After(time.Second):
+			t.Fatalf("fast subscriber did not get notification %d", i)
+		}
+	}
+	// The slow subscriber fell behind by more than its queue and was disconnected.
+	deadline := time.Now().Add(time.Second)
+	for broker.Subscribers("news") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("slow subscriber not removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		for range slowNews {
+		}
+	}()
+	select {
+	case <-slow.DisconnectNotify():
+	case <-time.After(time.Second):
+		t.Fatal("slow subscriber not disconnected")
+	}
+}
@@ -0,0 +1,162 @@
+package birpc
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is sent back to the caller when a request exceeds the rate
+// limits of the connection.
+var ErrRateLimited = errors.New("birpc: rate limit exceeded")
+
+// RateLimit configures a token bucket that refills at Rate tokens per second
+// and holds at most Burst tokens. Every request takes one token.
+// A zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits configures the limits applied to the requests received on a connection.
+// Internal _goRPC_ calls are never limited.
+type RateLimits struct {
+	Conn    RateLimit            // limit for all requests of the connection
+	Methods map[string]RateLimit // additional limits per method name
+
+	// Delay makes requests over the limit wait for a token instead of
+	// being answered with ErrRateLimited. Requests wait before their handler
+	// runs, without holding back the other messages of the peer or taking a
+	// worker of the pools, and stop waiting when they are canceled or the
+	// connection is closed, giving their tokens back.
+	// In blocking mode, where handlers run in the read loop, waiting holds
+	// back the messages of the peer like the handlers do.
+	Delay bool
+
+	// MaxDelay bounds how long a request may wait in Delay mode, and with it
+	// how many requests may be waiting. Requests that would wait longer are
+	// answered with ErrRateLimited. Zero means one second.
+	MaxDelay time.Duration
+}
+
+// defaultMaxDelay is the MaxDelay of RateLimits that do not set it.
+const defaultMaxDelay = time.Second
+
+// rateLimiter applies RateLimits to a single connection.
+type rateLimiter struct {
+	mu       sync.Mutex // the read loop takes tokens, delayed requests give them back
+	delay    bool
+	maxDelay time.Duration
+	conn     *tokenBucket
+	methods  map[string]*tokenBucket
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	r := &rateLimiter{
+		delay:    limits.Delay,
+		maxDelay: limits.MaxDelay,
+		conn:     newTokenBucket(limits.Conn),
+		methods:  make(map[string]*tokenBucket, len(limits.Methods)),
+	}
+	if r.maxDelay <= 0 {
+		r.maxDelay = defaultMaxDelay
+	}
+	for method, l := range limits.Methods {
+		if b := newTokenBucket(l); b != nil {
+			r.methods[method] = b
+		}
+	}
+	return r
+}
+
+// reserve takes the tokens needed by a request for method.
+// It returns how long the request must wait before running,
+// or false if the request must be rejected.
+func (r *rateLimiter) reserve(method string) (time.Duration, bool) {
+	if strings.HasPrefix(method, "_goRPC_") {
+		return 0, true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	buckets := [2]*tokenBucket{r.conn, r.methods[method]}
+	var wait time.Duration
+	for _, b := range buckets {
+		if b == nil {
+			continue
+		}
+		w := b.wait(now)
+		if w > 0 && !r.delay {
+			return 0, false
+		}
+		if w > wait {
+			wait = w
+		}
+	}
+	if wait > r.maxDelay {
+		return 0, false
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return wait, true
+}
+
+// release gives back the tokens taken by a request for method
+// that was canceled while waiting.
+func (r *rateLimiter) release(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range [2]*tokenBucket{r.conn, r.methods[method]} {
+		if b != nil {
+			b.tokens = math.Min(b.tokens+1, b.burst)
+		}
+	}
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64 // negative when tokens have been reserved in advance
+	last   time.Time
+}
+
+func newTokenBucket(l RateLimit) *tokenBucket {
+	if l.Rate <= 0 {
+		return nil
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   l.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// wait returns how long a request taking a token now has to wait until it is
+// due, the token being borrowed from the future if none is left.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
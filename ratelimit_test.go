@@ -0,0 +1,173 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimits(t *testing.T) {
+	srv := NewServer()
+	srv.SetRateLimits(RateLimits{
+		Conn: RateLimit{Rate: 0.001, Burst: 2},
+		Methods: map[string]RateLimit{
+			"slow": {Rate: 0.001, Burst: 1},
+		},
+	})
+	echo := func(ctx context.Context, i int, reply *int) error {
+		*reply = i
+		return nil
+	}
+	srv.Handle("fast", echo)
+	srv.Handle("slow", echo)
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	var rep int
+	for i, tc := range []struct {
+		method  string
+		limited bool
+	}{
+		{"slow", false},
+		{"slow", true}, // method bucket empty, takes no connection token
+		{"fast", false},
+		{"fast", true}, // connection bucket empty
+	} {
+		err := clt.Call(context.Background(), tc.method, i, &rep)
+		if tc.limited {
+			if err == nil || err.Error() != ErrRateLimited.Error() {
+				t.Fatalf("call %d: expected rate limit error, got: %v", i, err)
+			}
+		} else if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimitsDelay(t *testing.T) {
+	srv := NewServer()
+	srv.SetRateLimits(RateLimits{
+		Conn:  RateLimit{Rate: 20, Burst: 1},
+		Delay: true,
+	})
+	srv.Handle("echo", func(ctx context.Context, i int, reply *int) error {
+		*reply = i
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	start := time.Now()
+	var rep int
+	for i := 0; i < 3; i++ {
+		if err := clt.Call(context.Background(), "echo", i, &rep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first call uses the burst, the next two wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("calls were not delayed: %v", elapsed)
+	}
+}
+
+func TestRateLimitsDelayDoesNotBlock(t *testing.T) {
+	srv := NewServer()
+	srv.SetRateLimits(RateLimits{
+		Methods:  map[string]RateLimit{"slow": {Rate: 0.001, Burst: 1}},
+		Delay:    true,
+		MaxDelay: time.Hour,
+	})
+	// Delayed requests do not take the only worker.
+	srv.SetConnWorkers(1, 0)
+	srv.Handle("slow", func(ctx context.Context, i int, reply *int) error { return nil })
+	srv.Handle("fast", func(ctx context.Context, i int, reply *int) error { return nil })
+
+	cconn, sconn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		srv.ServeCodec(NewGobCodec(sconn))
+		close(served)
+	}()
+	clt := NewClient(cconn)
+	go clt.Run()
+
+	var rep int
+	if err := clt.Call(context.Background(), "slow", 0, &rep); err != nil {
+		t.Fatal(err)
+	}
+	delayed := clt.Go("slow", 1, &rep, nil) // waits about 1000s for a token
+	// Other requests are still read and run meanwhile.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := clt.Call(ctx, "fast", 0, &rep); err != nil {
+		t.Fatal(err)
+	}
+	clt.Close()
+	select {
+	case <-delayed.Done:
+	case <-time.After(time.Second):
+		t.Fatal("delayed call not ended by Close")
+	}
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func TestRateLimitsMaxDelay(t *testing.T) {
+	srv := NewServer()
+	srv.SetRateLimits(RateLimits{
+		Conn:     RateLimit{Rate: 2, Burst: 1},
+		Delay:    true,
+		MaxDelay: 600 * time.Millisecond,
+	})
+	srv.Handle("echo", func(ctx context.Context, i int, reply *int) error {
+		*reply = i
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	var rep int
+	if err := clt.Call(context.Background(), "echo", 0, &rep); err != nil {
+		t.Fatal(err)
+	}
+	// Waits 500ms for a token, until it is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		canceled <- clt.Call(ctx, "echo", 1, &rep)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// Over the maximum delay, behind the canceled call.
+	if err := clt.Call(context.Background(), "echo", 2, &rep); err == nil || err.Error() != ErrRateLimited.Error() {
+		t.Fatalf("expected rate limit error, got: %v", err)
+	}
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Fatalf("expected canceled, got: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // for the cancel to reach the handler
+	// The canceled call gave its token back, so this one waits about 400ms.
+	start := time.Now()
+	if err := clt.Call(context.Background(), "echo", 3, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("call was not delayed: %v", elapsed)
+	}
+}
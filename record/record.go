@@ -0,0 +1,202 @@
+// Package record captures the traffic of birpc connections and replays it.
+//
+// A Recorder wraps the codec of a connection and writes every message it
+// reads or writes to a recording, one JSON object per line, with its time,
+// direction, header and body. The recording is not a copy of the bytes on the
+// wire: bodies are the values the connection decoded or encoded, encoded again
+// as JSON, whatever the codec. Bodies that are missing from the recording have
+// the reason in the unrecorded field: "discarded" for those the connection did
+// not decode, like that of a request for an unknown method, and the error for
+// those that failed to be read or to be encoded as JSON, like a NaN.
+//
+// A Replayer is a codec feeding the incoming messages of a recording to a
+// Server or Client, and collecting what it writes back, for regression tests.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+// Direction tells whether a message was received or sent.
+type Direction string
+
+const (
+	In  Direction = "in"  // read from the connection
+	Out Direction = "out" // written to the connection
+)
+
+// Entry is a recorded message. Requests have a method, responses have none.
+type Entry struct {
+	Time   time.Time         `json:"time"`
+	Dir    Direction         `json:"dir"`
+	Seq    uint64            `json:"seq"`
+	Method string            `json:"method,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Stream birpc.StreamFrame `json:"stream,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+
+	// Unrecorded tells why the body is missing, if it is.
+	Unrecorded string `json:"unrecorded,omitempty"`
+}
+
+// IsRequest reports whether the entry is a request rather than a response.
+func (e *Entry) IsRequest() bool {
+	return e.Method != ""
+}
+
+func (e *Entry) setRequest(req *birpc.Request) {
+	e.Seq = req.Seq
+	e.Method = req.Method
+	e.Stream = req.Stream
+	e.Header = req.Header
+}
+
+func (e *Entry) setResponse(resp *birpc.Response) {
+	e.Seq = resp.Seq
+	e.Error = resp.Error
+	e.Stream = resp.Stream
+}
+
+// unrecordedDiscarded is the Unrecorded reason of the bodies not decoded.
+const unrecordedDiscarded = "discarded"
+
+// setBody records body as JSON, or why it cannot be.
+func (e *Entry) setBody(body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		e.Unrecorded = err.Error()
+		return
+	}
+	e.Body = b
+}
+
+// ReadAll reads the entries of a recording.
+func ReadAll(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Recorder is a codec recording the messages passing through another one.
+// Written messages are recorded as they are about to be sent, so a message
+// failing to be sent is recorded as well, and messages written by several
+// goroutines at once may be recorded in a different order than they were sent.
+type Recorder struct {
+	codec birpc.Codec
+
+	read Entry // message whose header was read, waiting for its body
+
+	mu  sync.Mutex // protects enc and err
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder passing messages to and from codec,
+// and writing them to w. It does not close w.
+func NewRecorder(codec birpc.Codec, w io.Writer) *Recorder {
+	return &Recorder{
+		codec: codec,
+		enc:   json.NewEncoder(w),
+	}
+}
+
+// Err returns the first error writing the recording, if any.
+// The connection is not affected by such errors.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// write writes e to the recording.
+func (r *Recorder) write(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) ReadHeader(req *birpc.Request, resp *birpc.Response) error {
+	if err := r.codec.ReadHeader(req, resp); err != nil {
+		return err
+	}
+	r.read = Entry{Time: time.Now(), Dir: In}
+	if req.Method != "" {
+		r.read.setRequest(req)
+	} else {
+		r.read.setResponse(resp)
+	}
+	return nil
+}
+
+func (r *Recorder) ReadRequestBody(body interface{}) error {
+	return r.readBody(r.codec.ReadRequestBody, body)
+}
+
+func (r *Recorder) ReadResponseBody(body interface{}) error {
+	return r.readBody(r.codec.ReadResponseBody, body)
+}
+
+func (r *Recorder) readBody(read func(interface{}) error, body interface{}) error {
+	err := read(body)
+	switch {
+	case err != nil:
+		r.read.Unrecorded = err.Error()
+	case body == nil:
+		r.read.Unrecorded = unrecordedDiscarded
+	default:
+		r.read.setBody(body)
+	}
+	r.write(&r.read)
+	return err
+}
+
+func (r *Recorder) WriteRequest(req *birpc.Request, body interface{}) error {
+	e := Entry{Time: time.Now(), Dir: Out}
+	e.setRequest(req)
+	e.setBody(body)
+	// Recorded before it is sent, so that it comes before the messages
+	// answering it, without holding back the read loop while writing.
+	r.write(&e)
+	return r.codec.WriteRequest(req, body)
+}
+
+func (r *Recorder) WriteResponse(resp *birpc.Response, body interface{}) error {
+	e := Entry{Time: time.Now(), Dir: Out}
+	e.setResponse(resp)
+	if b, ok := body.(*birpc.Response); !ok || b != resp {
+		// Error responses carry the header in place of a body.
+		e.setBody(body)
+	}
+	r.write(&e)
+	return r.codec.WriteResponse(resp, body)
+}
+
+func (r *Recorder) Close() error {
+	return r.codec.Close()
+}
+
+// RemoteAddr returns the address of the peer, if the wrapped codec knows it.
+func (r *Recorder) RemoteAddr() net.Addr {
+	if ra, ok := r.codec.(interface{ RemoteAddr() net.Addr }); ok {
+		return ra.RemoteAddr()
+	}
+	return nil
+}
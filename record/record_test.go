@@ -0,0 +1,190 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+func newServer() *birpc.Server {
+	srv := birpc.NewServer()
+	srv.Handle("greet", func(ctx context.Context, greeting string, reply *string) error {
+		var name string
+		if err := birpc.ClientValueFromContext(ctx).Call(ctx, "name", 0, &name); err != nil {
+			return err
+		}
+		*reply = greeting + " " + name
+		return nil
+	})
+	srv.Handle("fail", func(ctx context.Context, _ int, _ *int) error {
+		return errors.New("failed")
+	})
+	return srv
+}
+
+// withoutTime returns the entries with the time left out, for comparison.
+func withoutTime(entries []Entry) []Entry {
+	stripped := make([]Entry, len(entries))
+	for i, e := range entries {
+		stripped[i] = Entry{Dir: e.Dir, Seq: e.Seq, Method: e.Method, Error: e.Error,
+			Stream: e.Stream, Header: e.Header, Body: e.Body}
+	}
+	return stripped
+}
+
+func TestRecordReplay(t *testing.T) {
+	var recording bytes.Buffer
+	cconn, sconn := net.Pipe()
+	rec := NewRecorder(birpc.NewGobCodec(sconn), &recording)
+	served := make(chan struct{})
+	go func() {
+		newServer().ServeCodec(rec)
+		close(served)
+	}()
+	clt := birpc.NewClient(cconn)
+	clt.Handle("name", func(ctx context.Context, _ int, reply *string) error {
+		*reply = "bob"
+		return nil
+	})
+	go clt.Run()
+
+	var reply string
+	if err := clt.Call(context.Background(), "greet", "hello", &reply); err != nil || reply != "hello bob" {
+		t.Fatalf("not expected: %q, %v", reply, err)
+	}
+	if err := clt.Call(context.Background(), "fail", 1, new(int)); err == nil {
+		t.Fatal("expected error")
+	}
+	clt.Close()
+	<-served
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadAll(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Dir: In, Seq: 1, Method: "greet", Body: []byte(`"hello"`)},
+		{Dir: Out, Seq: 1, Method: "name", Body: []byte(`0`)},
+		{Dir: In, Seq: 1, Body: []byte(`"bob"`)},
+		{Dir: Out, Seq: 1, Body: []byte(`"hello bob"`)},
+		{Dir: In, Seq: 2, Method: "fail", Body: []byte(`1`)},
+		{Dir: Out, Seq: 2, Error: "failed", Body: []byte(`0`)},
+	}
+	if got := withoutTime(entries); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recorded:\n%+v\nwant:\n%+v", got, want)
+	}
+
+	replayer := NewReplayer(entries)
+	newServer().ServeCodec(replayer)
+	var sent []Entry
+	for _, e := range entries {
+		if e.Dir == Out {
+			sent = append(sent, e)
+		}
+	}
+	if got := withoutTime(replayer.Written()); !reflect.DeepEqual(got, withoutTime(sent)) {
+		t.Fatalf("replayed:\n%+v\nwant:\n%+v", got, withoutTime(sent))
+	}
+}
+
+func TestRecordGaps(t *testing.T) {
+	var recording bytes.Buffer
+	cconn, sconn := net.Pipe()
+	rec := NewRecorder(birpc.NewGobCodec(sconn), &recording)
+	srv := birpc.NewServer()
+	srv.Handle("half", func(ctx context.Context, f float64, reply *float64) error {
+		*reply = f / 2
+		return nil
+	})
+	served := make(chan struct{})
+	go func() {
+		srv.ServeCodec(rec)
+		close(served)
+	}()
+	clt := birpc.NewClient(cconn)
+	go clt.Run()
+
+	var reply float64
+	if err := clt.Call(context.Background(), "half", math.NaN(), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := clt.Call(context.Background(), "missing", 1, &reply); err == nil {
+		t.Fatal("expected error")
+	}
+	clt.Close()
+	<-served
+
+	entries, err := ReadAll(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("not expected: %+v", entries)
+	}
+	for i, want := range []string{"unsupported value: NaN", "unsupported value: NaN", "discarded"} {
+		if e := entries[i]; e.Body != nil || !strings.Contains(e.Unrecorded, want) {
+			t.Fatalf("entry %d: expected %q unrecorded, got: %+v", i, want, e)
+		}
+	}
+	if e := entries[3]; e.Unrecorded != "" {
+		t.Fatalf("error response: not expected: %+v", e)
+	}
+}
+
+func TestRecordBlockedWrite(t *testing.T) {
+	var recording bytes.Buffer
+	cconn, sconn := net.Pipe()
+	echo := func(ctx context.Context, data []byte, reply *[]byte) error {
+		*reply = data
+		return nil
+	}
+	// The peer answers from its read loop, so its writes wait for the recorder
+	// to read while the recorder writes.
+	rec := birpc.NewClientWithCodec(NewRecorder(birpc.NewGobCodec(sconn), &recording))
+	peer := birpc.NewClient(cconn)
+	peer.SetBlocking(true)
+	for _, c := range []*birpc.Client{rec, peer} {
+		c.Handle("echo", echo)
+		go c.Run()
+		defer c.Close()
+	}
+
+	data := bytes.Repeat([]byte{'x'}, 64<<10)
+	const callers = 4
+	errs := make(chan error, 2*callers)
+	for _, c := range []*birpc.Client{rec, peer} {
+		for i := 0; i < callers; i++ {
+			go func(c *birpc.Client) {
+				for i := 0; i < 10; i++ {
+					var reply []byte
+					if err := c.Call(context.Background(), "echo", data, &reply); err != nil {
+						errs <- err
+						return
+					}
+				}
+				errs <- nil
+			}(c)
+		}
+	}
+	for i := 0; i < 2*callers; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlock")
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cgrates/birpc"
+)
+
+// defaultReplayTimeout is how long a Replayer waits for the messages expected
+// before an incoming one, by default.
+const defaultReplayTimeout = time.Second
+
+// Replayer is a codec replaying a recording to the Server or Client using it.
+// It feeds it the incoming messages of the recording, in order, and collects
+// the messages it writes, which may then be compared with the recorded ones.
+//
+// Before each incoming message, the Replayer waits until as many messages have
+// been written as the recording had sent by then, so that replies to the calls
+// made by the connection arrive after the calls. Once the recording is over,
+// it waits for the remaining messages and reports the end of the connection.
+type Replayer struct {
+	entries []Entry
+	timeout time.Duration
+
+	mu      sync.Mutex
+	next    int    // index of the next entry to replay
+	sent    int    // outgoing entries of the recording before next
+	body    *Entry // message whose header was read, for its body
+	written []Entry
+	changed chan struct{} // closed when written or closed change
+	closed  bool
+}
+
+// NewReplayer returns a Replayer for the recorded entries.
+func NewReplayer(entries []Entry) *Replayer {
+	return &Replayer{
+		entries: entries,
+		timeout: defaultReplayTimeout,
+		changed: make(chan struct{}),
+	}
+}
+
+// SetTimeout sets how long to wait for the messages expected before an
+// incoming one, after which the message is replayed anyway.
+func (r *Replayer) SetTimeout(d time.Duration) {
+	r.mu.Lock()
+	r.timeout = d
+	r.mu.Unlock()
+}
+
+// Written returns the messages written so far, in order.
+func (r *Replayer) Written() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.written...)
+}
+
+// notify wakes the readers waiting for changes. It must be called with r.mu held.
+func (r *Replayer) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// wait waits, with r.mu held, until n messages have been written,
+// the Replayer is closed or the timeout expires.
+func (r *Replayer) wait(n int) {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+	for len(r.written) < n && !r.closed {
+		changed := r.changed
+		r.mu.Unlock()
+		select {
+		case <-changed:
+			r.mu.Lock()
+		case <-timer.C:
+			r.mu.Lock()
+			return
+		}
+	}
+}
+
+func (r *Replayer) ReadHeader(req *birpc.Request, resp *birpc.Response) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.next < len(r.entries) && r.entries[r.next].Dir != In {
+		r.next++
+		r.sent++
+	}
+	r.wait(r.sent)
+	if r.closed || r.next == len(r.entries) {
+		return io.EOF
+	}
+	e := &r.entries[r.next]
+	r.next++
+	if e.IsRequest() {
+		*req = birpc.Request{Seq: e.Seq, Method: e.Method, Stream: e.Stream, Header: e.Header}
+	} else {
+		*resp = birpc.Response{Seq: e.Seq, Error: e.Error, Stream: e.Stream}
+	}
+	r.body = e
+	return nil
+}
+
+func (r *Replayer) ReadRequestBody(body interface{}) error {
+	return r.readBody(body)
+}
+
+func (r *Replayer) ReadResponseBody(body interface{}) error {
+	return r.readBody(body)
+}
+
+func (r *Replayer) readBody(body interface{}) error {
+	r.mu.Lock()
+	e := r.body
+	r.body = nil
+	r.mu.Unlock()
+	if body == nil || e == nil {
+		return nil
+	}
+	if e.Unrecorded != "" {
+		return fmt.Errorf("record: body of message %d was not recorded: %s", e.Seq, e.Unrecorded)
+	}
+	if len(e.Body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Body, body)
+}
+
+func (r *Replayer) WriteRequest(req *birpc.Request, body interface{}) error {
+	e := Entry{Time: time.Now(), Dir: Out}
+	e.setRequest(req)
+	e.setBody(body)
+	return r.write(&e)
+}
+
+func (r *Replayer) WriteResponse(resp *birpc.Response, body interface{}) error {
+	e := Entry{Time: time.Now(), Dir: Out}
+	e.setResponse(resp)
+	if b, ok := body.(*birpc.Response); !ok || b != resp {
+		e.setBody(body)
+	}
+	return r.write(&e)
+}
+
+func (r *Replayer) write(e *Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+	r.written = append(r.written, *e)
+	r.notify()
+	return nil
+}
+
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		r.notify()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Caller makes calls, it is implemented by Client, Pool and Retrier.
+type Caller interface {
+	Call(ctx context.Context, method string, args interface{}, reply interface{}) error
+}
+
+// RetryPolicy configures how a failed call is retried.
+// Only calls to idempotent methods may be retried, since a call that failed
+// because the connection dropped may have been handled already.
+type RetryPolicy struct {
+	MaxAttempts int                             // attempts in total, including the first one
+	Backoff     func(attempt int) time.Duration // wait before the given retry, starting from 1; nil retries at once
+	Retryable   func(err error) bool            // errors worth retrying, nil for IsConnectionError
+}
+
+// ExponentialBackoff returns a RetryPolicy.Backoff waiting base before the
+// first retry, and doubling the wait for every retry up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// IsConnectionError reports whether err means the call failed because of the
+// connection rather than the handler, or because the server was busy.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrShutdown), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrClosedPipe), errors.Is(err, ErrKeepaliveTimeout), errors.Is(err, ErrNoConnection),
+		errors.As(err, &netErr):
+		return true
+	}
+	serr, ok := err.(ServerError)
+	return ok && string(serr) == ErrServerBusy.Error()
+}
+
+type retryContextKey struct{}
+
+// WithRetry returns a context marking calls made with it as idempotent,
+// to be retried with policy by a Retrier. It overrides the policy of the method.
+func WithRetry(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryContextKey{}, policy)
+}
+
+// Retrier retries the failed calls of idempotent methods made through it.
+// Retrying only helps when the next attempt may get a working connection,
+// as with a Pool, which dials again after a connection drops.
+type Retrier struct {
+	caller Caller
+
+	mu       sync.RWMutex
+	policies map[string]RetryPolicy
+}
+
+// NewRetrier returns a Retrier making the calls with c.
+func NewRetrier(c Caller) *Retrier {
+	return &Retrier{
+		caller:   c,
+		policies: make(map[string]RetryPolicy),
+	}
+}
+
+// SetPolicy marks method as idempotent, retrying its calls with policy.
+func (r *Retrier) SetPolicy(method string, policy RetryPolicy) {
+	r.mu.Lock()
+	r.policies[method] = policy
+	r.mu.Unlock()
+}
+
+// Call makes the call, retrying it while it fails with a retryable error,
+// if the method is idempotent and attempts are left.
+func (r *Retrier) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	policy, ok := ctx.Value(retryContextKey{}).(RetryPolicy)
+	if !ok {
+		r.mu.RLock()
+		policy, ok = r.policies[method]
+		r.mu.RUnlock()
+	}
+	if !ok {
+		return r.caller.Call(ctx, method, args, reply)
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsConnectionError
+	}
+	for attempt := 1; ; attempt++ {
+		err := r.caller.Call(ctx, method, args, reply)
+		if err == nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+		debugLogger.Debug("birpc: retrying call", "method", method, "attempt", attempt, "error", err)
+		if policy.Backoff == nil {
+			continue
+		}
+		t := time.NewTimer(policy.Backoff(attempt))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetrier(t *testing.T) {
+	var calls int32
+	srv := NewServer()
+	handler := func(ctx context.Context, _ int, reply *int32) error {
+		n := atomic.AddInt32(&calls, 1)
+		if n%3 != 0 {
+			// Drop the connection before replying.
+			ClientValueFromContext(ctx).Close()
+			return nil
+		}
+		*reply = n
+		return nil
+	}
+	srv.Handle("get", handler)
+	srv.Handle("put", handler)
+	srv.Handle("fail", func(ctx context.Context, _ int, _ *int32) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("handler failed")
+	})
+
+	pool := NewPool([]string{"srv"}, func(context.Context, string) (*Client, error) {
+		cconn, sconn := net.Pipe()
+		go srv.ServeCodec(NewGobCodec(sconn))
+		return NewClient(cconn), nil
+	}, RoundRobin)
+	defer pool.Close()
+	r := NewRetrier(pool)
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: ExponentialBackoff(time.Millisecond, 4*time.Millisecond)}
+	r.SetPolicy("get", policy)
+	r.SetPolicy("fail", policy)
+
+	var n int32
+	if err := r.Call(context.Background(), "get", 0, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("not expected: %d", n)
+	}
+
+	// put is not idempotent unless marked so for the call.
+	if err := r.Call(context.Background(), "put", 0, &n); !IsConnectionError(err) {
+		t.Fatalf("expected connection error, got: %v", err)
+	}
+	if err := r.Call(WithRetry(context.Background(), policy), "put", 0, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Fatalf("not expected: %d", n)
+	}
+
+	// Handler errors are not retried.
+	if err := r.Call(context.Background(), "fail", 0, &n); err == nil || err.Error() != "handler failed" {
+		t.Fatalf("expected handler error, got: %v", err)
+	}
+	if calls != 7 {
+		t.Fatalf("handler error was retried: %d calls", calls)
+	}
+}
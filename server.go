@@ -2,14 +2,18 @@ package birpc
 
 import (
 	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"reflect"
+	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/cenkalti/hub"
+	"github.com/cgrates/birpc/internal/svc"
 )
 
 // Precompute the reflect type for error.  Can't use error directly
@@ -33,6 +37,14 @@ func ClientValueFromContext(ctx context.Context) *Client {
 	return c
 }
 
+type notificationContextKey struct{}
+
+// IsNotification reports whether the handler called with ctx is handling
+// a notification, whose reply is not sent back.
+func IsNotification(ctx context.Context) bool {
+	return ctx.Value(notificationContextKey{}) != nil
+}
+
 const (
 	clientConnected hub.Kind = iota
 	clientDisconnected
@@ -40,14 +52,42 @@ const (
 
 // Server responds to RPC requests made by Client.
 type Server struct {
-	handlers map[string]*handler
+	handlers *handlerMap
 	eventHub *hub.Hub
+	acl      *ACL
+	pool     *WorkerPool
+	// per connection worker pool settings, no pool if connWorkers is zero
+	connWorkers int
+	connQueue   int
+	limits      *RateLimits
+	keyFunc     KeyFunc
+	keepalive   time.Duration
+	maxMissed   int
+	idle        time.Duration
+	fallback    FallbackFunc
+	metrics     Metrics
+	metricsSubs []func() // unsubscribe the metrics from the connection events
+	tracer      Tracer
+	logger      *slog.Logger
+	accessLog   bool
 }
 
 type handler struct {
-	fn        reflect.Value
+	fn        reflect.Value // registered function, not set for typed handlers
 	argType   reflect.Type
 	replyType reflect.Type
+	upload    bool // the arguments are a channel of streamed items
+	stream    bool // the reply is a *ServerStream
+	fallback  bool // calls the FallbackFunc for a method without a handler
+
+	// newArgs returns a pointer to decode the arguments into.
+	newArgs func() interface{}
+	// newReply returns a pointer to the reply to fill in.
+	newReply func() interface{}
+	// args returns the arguments as the handler receives them.
+	args func(decoded interface{}) interface{}
+	// invoke calls the handler with the decoded arguments and the reply.
+	invoke func(ctx context.Context, decoded, reply interface{}) error
 }
 
 type connectionEvent struct {
@@ -63,59 +103,182 @@ func (disconnectionEvent) Kind() hub.Kind { return clientDisconnected }
 
 // NewServer returns a new Server.
 func NewServer() *Server {
-	return &Server{
-		handlers: make(map[string]*handler),
+	s := &Server{
+		handlers: newHandlerMap(nil),
 		eventHub: &hub.Hub{},
 	}
+	addInternalHandlers(s.handlers)
+	return s
 }
 
 // Handle registers the handler function for the given method. If a handler already exists for method, Handle panics.
+// Handlers may be registered while the server is serving connections.
 func (s *Server) Handle(method string, handlerFunc interface{}) {
-	addHandler(s.handlers, method, handlerFunc)
+	s.handlers.add(method, newHandler(method, handlerFunc))
+}
+
+// Replace registers the handler function for the given method,
+// replacing the existing handler if there is one.
+// Requests already being handled complete with the old handler.
+func (s *Server) Replace(method string, handlerFunc interface{}) {
+	checkReplaceable(method)
+	s.handlers.replace(method, newHandler(method, handlerFunc))
+}
+
+// Unhandle removes the handler of the given method, reporting whether there was one.
+// Requests already being handled complete, later ones fail with "can't find method".
+func (s *Server) Unhandle(method string) bool {
+	return !isInternal(method) && s.handlers.remove(method)
+}
+
+// SetACL restricts the methods that connected clients may call.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetACL(acl *ACL) {
+	s.acl = acl
+}
+
+// SetWorkerPool makes all connections run their handlers on pool,
+// bounding the number of concurrent handlers of the whole server.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetWorkerPool(pool *WorkerPool) {
+	s.pool = pool
+}
+
+// SetConnWorkers gives every connection its own worker pool with the given
+// number of workers and queue size, bounding the number of concurrent handlers
+// of a single peer. It can be combined with SetWorkerPool.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetConnWorkers(workers, queueSize int) {
+	s.connWorkers = workers
+	s.connQueue = queueSize
+}
+
+// SetRateLimits limits the rate of requests each connected client may send.
+// Every connection gets its own token buckets.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetRateLimits(limits RateLimits) {
+	s.limits = &limits
+}
+
+// SetKeyFunc puts all connections in ordered mode, see Client.SetKeyFunc.
+// Ordering applies among the requests of a single connection.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetKeyFunc(keyFunc KeyFunc) {
+	s.keyFunc = keyFunc
+}
+
+// SetKeepalive makes the server ping every connected client, see Client.SetKeepalive.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetKeepalive(interval time.Duration, maxMissed int) {
+	s.keepalive = interval
+	s.maxMissed = maxMissed
 }
 
-func addHandler(handlers map[string]*handler, mname string, handlerFunc interface{}) {
-	if _, ok := handlers[mname]; ok {
-		panic("birpc: multiple registrations for " + mname)
+// SetIdleTimeout closes connections that carry no traffic, see Client.SetIdleTimeout.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idle = timeout
+}
+
+// addInternalHandlers registers the _goRPC_ service used by the protocol itself.
+func addInternalHandlers(handlers *handlerMap) {
+	rpc := &svc.GoRPC{}
+	handlers.add("_goRPC_.Cancel", newHandler("_goRPC_.Cancel", rpc.Cancel))
+	handlers.add(pingMethod, newHandler(pingMethod, rpc.Ping))
+	handlers.add(methodsMethod, newHandler(methodsMethod, listMethods))
+}
+
+// isInternal reports whether method belongs to the _goRPC_ service.
+func isInternal(method string) bool {
+	return strings.HasPrefix(method, "_goRPC_")
+}
+
+// checkReplaceable panics if method is one of the internal methods.
+func checkReplaceable(method string) {
+	if isInternal(method) {
+		panic("birpc: method " + method + " is internal and cannot be replaced")
 	}
+}
 
+func (s *Server) register(method string, h *handler) {
+	s.handlers.add(method, h)
+}
+
+// newHandler validates handlerFunc and returns the handler calling it for mname.
+func newHandler(mname string, handlerFunc interface{}) *handler {
 	method := reflect.ValueOf(handlerFunc)
 	mtype := method.Type()
 	// Method needs three ins: *client, *args, *reply.
 	if mtype.NumIn() != 3 {
-		log.Panicln("method", mname, "has wrong number of ins:", mtype.NumIn())
+		panic(fmt.Sprintf("birpc: method %s has wrong number of ins: %d", mname, mtype.NumIn()))
 	}
 	// First arg must be a pointer to birpc.Client.
 	if ctxType := mtype.In(0); ctxType != typeOfCtx {
-		log.Panicln("method", mname, "first argument", ctxType.String(), "not context.Context")
+		panic(fmt.Sprintf("birpc: method %s first argument %s not context.Context", mname, ctxType))
 	}
 	// Second arg need not be a pointer.
 	argType := mtype.In(1)
 	if !isExportedOrBuiltinType(argType) {
-		log.Panicln(mname, "argument type not exported:", argType)
+		panic(fmt.Sprintf("birpc: method %s argument type not exported: %s", mname, argType))
 	}
 	// Third arg must be a pointer.
 	replyType := mtype.In(2)
 	if replyType.Kind() != reflect.Ptr {
-		log.Panicln("method", mname, "reply type not a pointer:", replyType)
+		panic(fmt.Sprintf("birpc: method %s reply type not a pointer: %s", mname, replyType))
 	}
 	// Reply type must be exported.
 	if !isExportedOrBuiltinType(replyType) {
-		log.Panicln("method", mname, "reply type not exported:", replyType)
+		panic(fmt.Sprintf("birpc: method %s reply type not exported: %s", mname, replyType))
 	}
 	// Method needs one out.
 	if mtype.NumOut() != 1 {
-		log.Panicln("method", mname, "has wrong number of outs:", mtype.NumOut())
+		panic(fmt.Sprintf("birpc: method %s has wrong number of outs: %d", mname, mtype.NumOut()))
 	}
 	// The return type of the method must be error.
 	if returnType := mtype.Out(0); returnType != typeOfError {
-		log.Panicln("method", mname, "returns", returnType.String(), "not error")
+		panic(fmt.Sprintf("birpc: method %s returns %s not error", mname, returnType))
 	}
-	handlers[mname] = &handler{
+	return newReflectHandler(method, argType, replyType)
+}
+
+// newReflectHandler returns a handler that calls method through reflection.
+func newReflectHandler(method reflect.Value, argType, replyType reflect.Type) *handler {
+	h := &handler{
 		fn:        method,
 		argType:   argType,
 		replyType: replyType,
+		upload:    argType.Kind() == reflect.Chan,
+		stream:    replyType == typeOfServerStream,
+	}
+	// Arguments are always decoded into a pointer.
+	argIsValue := argType.Kind() != reflect.Ptr && !h.upload // if true, need to indirect before calling.
+	argElem := argType
+	if !argIsValue {
+		argElem = argType.Elem()
+	}
+	argValue := func(decoded interface{}) reflect.Value {
+		argv := reflect.ValueOf(decoded)
+		if argIsValue {
+			argv = argv.Elem()
+		}
+		return argv
+	}
+	h.newArgs = func() interface{} {
+		return reflect.New(argElem).Interface()
 	}
+	h.newReply = func() interface{} {
+		return reflect.New(replyType.Elem()).Interface()
+	}
+	h.args = func(decoded interface{}) interface{} {
+		return argValue(decoded).Interface()
+	}
+	h.invoke = func(ctx context.Context, decoded, reply interface{}) error {
+		returnValues := method.Call([]reflect.Value{reflect.ValueOf(ctx), argValue(decoded), reflect.ValueOf(reply)})
+		// The return value for the method is an error.
+		err, _ := returnValues[0].Interface().(error)
+		return err
+	}
+	return h
 }
 
 // Is this type exported or a builtin?
@@ -155,7 +318,7 @@ func (s *Server) Accept(lis net.Listener) {
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
-			log.Print("rpc.Serve: accept:", err.Error())
+			s.log().Error("birpc: accept failed", "addr", lis.Addr().String(), "error", err)
 			return
 		}
 		go s.ServeConn(conn)
@@ -185,8 +348,25 @@ func (s *Server) ServeCodecWithState(codec Codec, state *State) {
 	// Client also handles the incoming connections.
 	c := NewClientWithCodec(codec)
 	c.server = true
-	c.handlers = s.handlers
+	c.handlers = newHandlerMap(s.handlers)
 	c.State = state
+	c.acl = s.acl
+	c.sharedPool = s.pool
+	if s.limits != nil {
+		c.SetRateLimits(*s.limits)
+	}
+	c.SetKeyFunc(s.keyFunc)
+	c.SetKeepalive(s.keepalive, s.maxMissed)
+	c.SetIdleTimeout(s.idle)
+	c.fallback = s.fallback
+	c.metrics = s.metrics
+	c.tracer = s.tracer
+	c.logger = s.logger
+	c.accessLog = s.accessLog
+	if s.connWorkers > 0 {
+		c.pool = NewWorkerPool(s.connWorkers, s.connQueue)
+		defer c.pool.Close()
+	}
 
 	s.eventHub.Publish(connectionEvent{c})
 	c.Run()
@@ -0,0 +1,594 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// streamWindow is the number of items a stream sender may send ahead of
+// the acknowledgements of the receiver.
+const streamWindow = 64
+
+var typeOfServerStream = reflect.TypeOf((*ServerStream)(nil))
+
+// ErrStreamWindow is returned for client streaming calls whose caller sends
+// more items than it has been granted credits for.
+var ErrStreamWindow = errors.New("birpc: stream window exceeded")
+
+// ServerStream is the sending end of a server streaming call.
+// A handler registered with a *ServerStream in place of the reply pointer,
+// such as
+//
+//	func(ctx context.Context, args *Args, stream *birpc.ServerStream) error
+//
+// sends any number of items with Send. The stream ends when the handler
+// returns; the returned error, if any, is passed to the caller.
+type ServerStream struct {
+	c       *Client
+	ctx     context.Context
+	seq     uint64
+	limited bool // false if the caller did not open the call as a stream
+
+	mu      sync.Mutex // protects credits
+	credits uint64
+	wake    chan struct{}
+}
+
+// Send sends item to the caller. Once the caller falls behind by too many
+// items, Send blocks until it catches up. It fails when the call is canceled
+// or the connection is closed.
+// Items of a call made as a notification are discarded.
+func (s *ServerStream) Send(item interface{}) error {
+	if s.seq == 0 {
+		return nil
+	}
+	if s.limited {
+		if err := s.wait(); err != nil {
+			return err
+		}
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	resp := &Response{
+		Seq:    s.seq,
+		Stream: StreamData,
+	}
+	return s.c.codec.WriteResponse(resp, item)
+}
+
+// wait takes a credit, waiting for one if there are none left.
+func (s *ServerStream) wait() error {
+	for {
+		s.mu.Lock()
+		if s.credits > 0 {
+			s.credits--
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+func (s *ServerStream) grant(n uint64) {
+	s.mu.Lock()
+	s.credits += n
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stream is the receiving end of a server streaming call, see Client.Stream.
+type Stream struct {
+	c        *Client
+	ctx      context.Context
+	call     *Call
+	itemType reflect.Type
+	items    chan interface{}
+	consumed uint64 // items received since the last acknowledgement
+	finished bool   // the call is done, the items left are still handed out
+	err      error  // returned once no items are left
+}
+
+// Stream calls a streaming method, one whose handler takes a *ServerStream
+// in place of the reply. The items sent by the handler are decoded into new
+// values of the type item points to and returned by Recv, in order.
+// Canceling ctx cancels the call on the other end.
+func (c *Client) Stream(ctx context.Context, method string, args interface{}, item interface{}) *Stream {
+	itemType := reflect.TypeOf(item)
+	if itemType == nil || itemType.Kind() != reflect.Ptr {
+		panic("birpc: stream item must be a pointer")
+	}
+	s := &Stream{
+		c:        c,
+		ctx:      ctx,
+		itemType: itemType.Elem(),
+		items:    make(chan interface{}, streamWindow),
+	}
+	s.call = &Call{
+		Method: method,
+		Args:   args,
+		Done:   make(chan *Call, 2), // 2 for this call and cancel
+		stream: s,
+		ctx:    ctx,
+	}
+	c.send(s.call)
+	return s
+}
+
+// Recv returns the next item of the stream, a pointer of the type given to
+// Client.Stream. It returns io.EOF once the handler has returned without error,
+// and the error of the call otherwise.
+func (s *Stream) Recv() (interface{}, error) {
+	if s.finished {
+		// Items are queued before the call is done, hand them out first.
+		select {
+		case item := <-s.items:
+			return item, nil
+		default:
+			return nil, s.err
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	select {
+	case item := <-s.items:
+		return item, s.ack()
+	case <-s.call.Done:
+		s.finished = true
+		s.err = s.call.Error
+		if s.err == nil {
+			s.err = io.EOF
+		}
+		return s.Recv()
+	case <-s.ctx.Done():
+		s.c.cancel(s.call, s.ctx.Err())
+		s.err = s.ctx.Err()
+		return nil, s.err
+	}
+}
+
+// Close stops receiving the stream, canceling the call if it is still running.
+func (s *Stream) Close() error {
+	s.finished = false // drop the items left
+	if s.err == nil {
+		s.c.cancel(s.call, context.Canceled)
+		s.err = context.Canceled
+	}
+	return nil
+}
+
+// ack acknowledges a received item, telling the sender once
+// enough of them have been consumed.
+func (s *Stream) ack() error {
+	s.consumed++
+	if s.consumed < streamWindow/2 {
+		return nil
+	}
+	n := s.consumed
+	s.consumed = 0
+	return s.c.sendFrame(Request{Seq: s.call.seq, Method: s.call.Method, Stream: StreamAck}, n)
+}
+
+// deliver queues an item received for the stream.
+func (s *Stream) deliver() error {
+	item := reflect.New(s.itemType)
+	if err := s.c.codec.ReadResponseBody(item.Interface()); err != nil {
+		return err
+	}
+	select {
+	case s.items <- item.Interface():
+	default:
+		s.c.log().Debug("birpc: discarding stream item sent past the window", "method", s.call.Method, "seq", s.call.seq)
+	}
+	return nil
+}
+
+// sendFrame writes a stream frame on behalf of the caller of a streaming call.
+func (c *Client) sendFrame(req Request, body interface{}) error {
+	c.sending.Lock()
+	defer c.sending.Unlock()
+	if c.isClosed() {
+		return ErrShutdown
+	}
+	c.request = req
+	return c.codec.WriteRequest(&c.request, body)
+}
+
+// openServerStream registers the sending end of the streaming call req.
+func (c *Client) openServerStream(ctx context.Context, req Request) *ServerStream {
+	s := &ServerStream{
+		c:       c,
+		ctx:     ctx,
+		seq:     req.Seq,
+		limited: req.Stream == StreamOpen,
+		credits: streamWindow,
+		wake:    make(chan struct{}, 1),
+	}
+	if req.Seq != 0 {
+		c.streamMu.Lock()
+		if c.streams == nil {
+			c.streams = make(map[uint64]*ServerStream)
+		}
+		c.streams[req.Seq] = s
+		c.streamMu.Unlock()
+	}
+	return s
+}
+
+func (c *Client) closeServerStream(seq uint64) {
+	c.streamMu.Lock()
+	delete(c.streams, seq)
+	c.streamMu.Unlock()
+}
+
+// readStreamRequest reads a stream frame sent by the caller of a streaming call.
+func (c *Client) readStreamRequest(req *Request) error {
+	switch req.Stream {
+	case StreamData:
+		c.streamMu.Lock()
+		u := c.uploads[req.Seq]
+		c.streamMu.Unlock()
+		if u == nil {
+			// The handler has already returned.
+			return c.codec.ReadRequestBody(nil)
+		}
+		return u.receive()
+	case StreamEnd:
+		c.streamMu.Lock()
+		u := c.uploads[req.Seq]
+		c.streamMu.Unlock()
+		if u != nil {
+			u.end()
+		}
+		return c.codec.ReadRequestBody(nil)
+	case StreamAck:
+		var n uint64
+		if err := c.codec.ReadRequestBody(&n); err != nil {
+			return err
+		}
+		c.streamMu.Lock()
+		s := c.streams[req.Seq]
+		c.streamMu.Unlock()
+		if s != nil {
+			s.grant(n)
+		}
+		return nil
+	default:
+		c.log().Debug("birpc: discarding unexpected stream frame", "method", req.Method, "seq", req.Seq)
+		return c.codec.ReadRequestBody(nil)
+	}
+}
+
+// readStreamResponse reads a stream frame sent by the handler of call.
+func (c *Client) readStreamResponse(call *Call, resp *Response) error {
+	switch {
+	case resp.Stream == StreamData && call.stream != nil:
+		return call.stream.deliver()
+	case resp.Stream == StreamAck && call.upload != nil:
+		var n uint64
+		if err := c.codec.ReadResponseBody(&n); err != nil {
+			return err
+		}
+		call.upload.grant(n)
+		return nil
+	}
+	c.log().Debug("birpc: discarding unexpected stream frame", "method", call.Method, "seq", call.seq)
+	return c.codec.ReadResponseBody(nil)
+}
+
+// Upload is the sending end of a client streaming call, see Client.Upload.
+type Upload struct {
+	c        *Client
+	ctx      context.Context
+	call     *Call
+	finished chan struct{} // closed once the call is done
+	err      error         // set when finished is closed
+
+	mu       sync.Mutex // protects credits and canceled
+	credits  uint64
+	canceled error // why the call was canceled, if it was
+	wake     chan struct{}
+}
+
+// Upload calls a client streaming method, one whose handler takes a
+// receive-only channel of items in place of the args, such as
+//
+//	func(ctx context.Context, items <-chan *Item, reply *Reply) error
+//
+// The items are sent with Send. CloseAndRecv tells the handler that there
+// are no more items, which closes its channel, and waits for the reply.
+// Canceling ctx cancels the call on the other end.
+func (c *Client) Upload(ctx context.Context, method string, reply interface{}) *Upload {
+	u := &Upload{
+		c:        c,
+		ctx:      ctx,
+		finished: make(chan struct{}),
+		credits:  streamWindow,
+		wake:     make(chan struct{}, 1),
+	}
+	u.call = &Call{
+		Method: method,
+		Args:   true, // the request opening the call carries no data
+		Reply:  reply,
+		Done:   make(chan *Call, 2), // 2 for this call and cancel
+		upload: u,
+		ctx:    ctx,
+	}
+	go func() {
+		call := <-u.call.Done
+		u.err = call.Error
+		if call != u.call {
+			// The answer to the cancel request, the call itself never ends.
+			u.mu.Lock()
+			u.err = u.canceled
+			u.mu.Unlock()
+		}
+		close(u.finished)
+	}()
+	c.send(u.call)
+	return u
+}
+
+// Send sends item to the handler. Once the handler falls behind by too many
+// items, Send blocks until it catches up. If the handler has already returned,
+// Send returns its error, or ErrShutdown if it succeeded.
+func (u *Upload) Send(item interface{}) error {
+	for {
+		u.mu.Lock()
+		if u.credits > 0 {
+			u.credits--
+			u.mu.Unlock()
+			break
+		}
+		u.mu.Unlock()
+		select {
+		case <-u.wake:
+		case <-u.finished:
+			return u.finishedErr()
+		case <-u.ctx.Done():
+			return u.abort()
+		}
+	}
+	select {
+	case <-u.finished:
+		return u.finishedErr()
+	default:
+	}
+	return u.c.sendFrame(Request{Seq: u.call.seq, Method: u.call.Method, Stream: StreamData}, item)
+}
+
+// CloseAndRecv tells the handler that all items have been sent and waits for
+// it to return. The reply of the handler is decoded into the reply given to
+// Client.Upload.
+func (u *Upload) CloseAndRecv() error {
+	select {
+	case <-u.finished:
+		return u.err
+	default:
+	}
+	if err := u.c.sendFrame(Request{Seq: u.call.seq, Method: u.call.Method, Stream: StreamEnd}, true); err != nil {
+		return err
+	}
+	select {
+	case <-u.finished:
+		return u.err
+	case <-u.ctx.Done():
+		return u.abort()
+	}
+}
+
+// abort cancels the call once ctx is done, returning the error of ctx.
+func (u *Upload) abort() error {
+	err := u.ctx.Err()
+	u.mu.Lock()
+	if u.canceled == nil {
+		u.canceled = err
+	}
+	u.mu.Unlock()
+	u.c.cancel(u.call, err)
+	return err
+}
+
+func (u *Upload) finishedErr() error {
+	if u.err != nil {
+		return u.err
+	}
+	return ErrShutdown
+}
+
+func (u *Upload) grant(n uint64) {
+	u.mu.Lock()
+	u.credits += n
+	u.mu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// upload is the receiving end of a client streaming call being handled.
+// Items are queued as they are read and passed on to the channel of the
+// handler, acknowledging them to the caller as the handler takes them.
+type upload struct {
+	c        *Client
+	seq      uint64
+	itemType reflect.Type // type of the channel elements
+	ch       reflect.Value
+	done     chan struct{} // closed when the handler returns
+
+	mu      sync.Mutex // protects queue, ended, unacked, err and cancel
+	queue   []reflect.Value
+	ended   bool   // the caller has sent all items
+	unacked uint64 // items received and not acknowledged yet
+	err     error  // set if the caller went past the window
+	cancel  func() // cancels the handler, once it runs
+	wake    chan struct{}
+}
+
+// openUpload registers the receiving end of the client streaming call req
+// and returns the channel to pass to its handler.
+func (c *Client) openUpload(req *Request, chanType reflect.Type) interface{} {
+	u := &upload{
+		c:        c,
+		seq:      req.Seq,
+		itemType: chanType.Elem(),
+		ch:       reflect.MakeChan(reflect.ChanOf(reflect.BothDir, chanType.Elem()), 0),
+		done:     make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+	c.streamMu.Lock()
+	if c.uploads == nil {
+		c.uploads = make(map[uint64]*upload)
+	}
+	c.uploads[req.Seq] = u
+	c.streamMu.Unlock()
+	return u.ch.Convert(chanType).Interface()
+}
+
+// startUpload starts passing the items of the upload seq on to its handler,
+// which cancel cancels if the caller goes past the window.
+func (c *Client) startUpload(ctx context.Context, cancel func(), seq uint64) *upload {
+	c.streamMu.Lock()
+	u := c.uploads[seq]
+	c.streamMu.Unlock()
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	u.cancel = cancel
+	failed := u.err != nil
+	u.mu.Unlock()
+	if failed {
+		cancel()
+	}
+	go u.relay(ctx)
+	return u
+}
+
+// failure returns the error the upload failed with, if any.
+func (u *upload) failure() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.err
+}
+
+func (c *Client) closeUpload(seq uint64) {
+	c.streamMu.Lock()
+	u := c.uploads[seq]
+	delete(c.uploads, seq)
+	c.streamMu.Unlock()
+	if u != nil {
+		close(u.done)
+	}
+}
+
+// receive reads an item of the upload and queues it for the handler.
+func (u *upload) receive() error {
+	item := reflect.New(u.itemType)
+	if u.itemType.Kind() == reflect.Ptr {
+		item = reflect.New(u.itemType.Elem())
+	}
+	if err := u.c.codec.ReadRequestBody(item.Interface()); err != nil {
+		return err
+	}
+	if u.itemType.Kind() != reflect.Ptr {
+		item = item.Elem()
+	}
+	u.mu.Lock()
+	if u.err != nil {
+		u.mu.Unlock()
+		return nil
+	}
+	if u.unacked++; u.unacked > streamWindow {
+		// The caller ignores the credits, stop buffering its items.
+		u.err = ErrStreamWindow
+		u.queue = nil
+		cancel := u.cancel
+		u.mu.Unlock()
+		u.c.log().Debug("birpc: stream window exceeded", "seq", u.seq)
+		if cancel != nil {
+			cancel()
+		}
+		return nil
+	}
+	u.queue = append(u.queue, item)
+	u.mu.Unlock()
+	u.signal()
+	return nil
+}
+
+func (u *upload) end() {
+	u.mu.Lock()
+	u.ended = true
+	u.mu.Unlock()
+	u.signal()
+}
+
+func (u *upload) signal() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// relay passes the queued items on to the handler until the caller
+// has sent all of them or the handler returns.
+func (u *upload) relay(ctx context.Context) {
+	defer u.ch.Close()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: u.ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(u.done)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+	var consumed uint64
+	for {
+		u.mu.Lock()
+		if len(u.queue) == 0 {
+			ended := u.ended
+			u.mu.Unlock()
+			if ended {
+				return
+			}
+			select {
+			case <-u.wake:
+				continue
+			case <-u.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		cases[0].Send = u.queue[0]
+		u.queue[0] = reflect.Value{}
+		u.queue = u.queue[1:]
+		u.mu.Unlock()
+		if chosen, _, _ := reflect.Select(cases); chosen != 0 {
+			return
+		}
+		if consumed++; consumed >= streamWindow/2 {
+			u.mu.Lock()
+			u.unacked -= consumed
+			u.mu.Unlock()
+			resp := &Response{
+				Seq:    u.seq,
+				Stream: StreamAck,
+			}
+			if err := u.c.codec.WriteResponse(resp, consumed); err != nil {
+				u.c.log().Debug("birpc: error writing stream ack", "seq", resp.Seq, "error", err)
+			}
+			consumed = 0
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerStream(t *testing.T) {
+	type Record struct{ N int }
+
+	srv := NewServer()
+	srv.Handle("export", func(ctx context.Context, n int, stream *ServerStream) error {
+		for i := 0; i < n; i++ {
+			if err := stream.Send(&Record{N: i}); err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return errors.New("nothing to export")
+		}
+		return nil
+	})
+	canceled := make(chan struct{})
+	srv.Handle("follow", func(ctx context.Context, _ int, stream *ServerStream) error {
+		defer close(canceled)
+		for i := 0; ; i++ {
+			if err := stream.Send(&Record{N: i}); err != nil {
+				return err
+			}
+		}
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	// More items than fit in the window.
+	const n = 3 * streamWindow
+	stream := clt.Stream(context.Background(), "export", n, new(Record))
+	for i := 0; i < n; i++ {
+		item, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec := item.(*Record); rec.N != i {
+			t.Fatalf("not expected: %d", rec.N)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF, got: %v", err)
+	}
+
+	stream = clt.Stream(context.Background(), "export", 0, new(Record))
+	if _, err := stream.Recv(); err == nil || err.Error() != "nothing to export" {
+		t.Fatalf("expected handler error, got: %v", err)
+	}
+
+	// Closing the stream cancels the handler.
+	stream = clt.Stream(context.Background(), "follow", 0, new(Record))
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not canceled")
+	}
+
+	// A plain call to a streaming method ignores the items.
+	if err := clt.Call(context.Background(), "export", n, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	type CDR struct{ Cost int }
+
+	srv := NewServer()
+	srv.Handle("store", func(ctx context.Context, cdrs <-chan *CDR, total *int) error {
+		for cdr := range cdrs {
+			if cdr.Cost < 0 {
+				return errors.New("negative cost")
+			}
+			*total += cdr.Cost
+		}
+		return ctx.Err()
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	// More items than fit in the window.
+	const n = 3 * streamWindow
+	var total int
+	up := clt.Upload(context.Background(), "store", &total)
+	for i := 1; i <= n; i++ {
+		if err := up.Send(&CDR{Cost: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := up.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if total != n*(n+1)/2 {
+		t.Fatalf("not expected: %d", total)
+	}
+
+	// The handler returns before reading all items.
+	up = clt.Upload(context.Background(), "store", &total)
+	var err error
+	for i := 0; i < 10*streamWindow && err == nil; i++ {
+		err = up.Send(&CDR{Cost: -1})
+	}
+	if err == nil {
+		err = up.CloseAndRecv()
+	}
+	if err == nil || err.Error() != "negative cost" {
+		t.Fatalf("expected handler error, got: %v", err)
+	}
+
+	// Upload methods cannot be called with Call.
+	if err = clt.Call(context.Background(), "store", 1, &total); err == nil {
+		t.Fatal("expected error calling an upload method")
+	}
+}
+
+func TestStreamDrain(t *testing.T) {
+	type Record struct{ N int }
+
+	srv := NewServer()
+	srv.Handle("export", func(ctx context.Context, n int, stream *ServerStream) error {
+		for i := 0; i < n; i++ {
+			if err := stream.Send(&Record{N: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	const n = 10
+	stream := clt.Stream(context.Background(), "export", n, new(Record))
+	// Let the stream end before receiving anything.
+	for deadline := time.Now().Add(time.Second); clt.numPending() > 0 || len(stream.items) < n; {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < n; i++ {
+		item, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("item %d: %v", i, err)
+		}
+		if rec := item.(*Record); rec.N != i {
+			t.Fatalf("not expected: %d", rec.N)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF, got: %v", err)
+	}
+}
+
+func TestUploadWindow(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("hold", func(ctx context.Context, items <-chan int, _ *int) error {
+		// Take nothing, so that no credits are granted.
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	up := clt.Upload(context.Background(), "hold", new(int))
+	// Send past the window, ignoring the credits like a broken peer.
+	for i := 0; i <= streamWindow; i++ {
+		req := Request{Seq: up.call.seq, Method: "hold", Stream: StreamData}
+		if err := clt.sendFrame(req, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-up.finished:
+	case <-time.After(time.Second):
+		t.Fatal("upload not ended")
+	}
+	if err := up.err; err == nil || err.Error() != ErrStreamWindow.Error() {
+		t.Fatalf("expected window error, got: %v", err)
+	}
+}
+
+func TestStreamBlocking(t *testing.T) {
+	type Record struct{ N int }
+
+	cconn, sconn := net.Pipe()
+	srv := NewClient(sconn)
+	srv.SetBlocking(true)
+	srv.Handle("export", func(ctx context.Context, n int, stream *ServerStream) error {
+		for i := 0; i < n; i++ {
+			if err := stream.Send(&Record{N: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	go srv.Run()
+	defer srv.Close()
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// More items than fit in the window.
+	const n = 3 * streamWindow
+	stream := clt.Stream(ctx, "export", n, new(Record))
+	for i := 0; i < n; i++ {
+		item, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("item %d: %v", i, err)
+		}
+		if rec := item.(*Record); rec.N != i {
+			t.Fatalf("not expected: %d", rec.N)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF, got: %v", err)
+	}
+}
+
+func TestUploadBlocking(t *testing.T) {
+	cconn, sconn := net.Pipe()
+	srv := NewClient(sconn)
+	srv.SetBlocking(true)
+	srv.Handle("sum", func(ctx context.Context, items <-chan int, total *int) error {
+		for i := range items {
+			*total += i
+		}
+		return nil
+	})
+	go srv.Run()
+	defer srv.Close()
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// More items than fit in the window.
+	const n = 3 * streamWindow
+	var total int
+	up := clt.Upload(ctx, "sum", &total)
+	for i := 1; i <= n; i++ {
+		if err := up.Send(i); err != nil {
+			t.Fatalf("item %d: %v", i, err)
+		}
+	}
+	if err := up.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if total != n*(n+1)/2 {
+		t.Fatalf("not expected: %d", total)
+	}
+}
+
+func TestUploadCancel(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("hold", func(ctx context.Context, items <-chan int, _ *int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	up := clt.Upload(ctx, "hold", new(int))
+	if err := up.Send(1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := up.CloseAndRecv(); err != context.Canceled {
+		t.Fatalf("expected canceled, got: %v", err)
+	}
+	// The answer to the cancel request ends the upload, with the same error.
+	select {
+	case <-up.finished:
+	case <-time.After(time.Second):
+		t.Fatal("upload not ended")
+	}
+	if err := up.CloseAndRecv(); err != context.Canceled {
+		t.Fatalf("expected canceled once finished, got: %v", err)
+	}
+}
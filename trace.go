@@ -0,0 +1,33 @@
+package birpc
+
+import "context"
+
+// Tracer traces calls and the handling of requests,
+// see the otelbirpc package for OpenTelemetry.
+// Internal _goRPC_ calls are not traced.
+type Tracer interface {
+	// StartCall is called when an outgoing call is sent, with the context
+	// the call is made in, which is empty for calls made with Go.
+	// It may add metadata to header, which is sent along with the request.
+	// It returns the function called with the outcome of the call.
+	StartCall(ctx context.Context, method string, header map[string]string) (end func(err error))
+
+	// StartHandler is called before the handler of a request runs, with the
+	// header received with the request, which may be nil.
+	// It returns the context for the handler and the function called with
+	// its outcome.
+	StartHandler(ctx context.Context, method string, header map[string]string) (context.Context, func(err error))
+}
+
+// SetTracer makes the client trace its calls and handlers with t.
+// It must be called before Run.
+func (c *Client) SetTracer(t Tracer) {
+	c.tracer = t
+}
+
+// SetTracer makes the server trace the calls and handlers of all its
+// connections with t.
+// It must be called before the server starts accepting connections.
+func (s *Server) SetTracer(t Tracer) {
+	s.tracer = t
+}
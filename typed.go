@@ -0,0 +1,92 @@
+package birpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Registry is implemented by Server and Client, the types that handlers are registered with.
+type Registry interface {
+	Handle(method string, handlerFunc interface{})
+	register(method string, h *handler)
+}
+
+// HandleT registers a typed handler function for the given method of r.
+// Unlike Handle, mistakes in the handler signature are caught by the compiler,
+// and the handler is invoked, and its arguments decoded, without reflection.
+// Req may be a receive-only channel to handle client streaming calls.
+// If a handler already exists for method, HandleT panics.
+func HandleT[Req, Resp any](r Registry, method string, fn func(context.Context, Req) (Resp, error)) {
+	h := newTypedHandler[Req, Resp](method)
+	if h.stream {
+		panic("birpc: method " + method + " streams its reply, register it with HandleFunc")
+	}
+	h.invoke = func(ctx context.Context, decoded, reply interface{}) error {
+		resp, err := fn(ctx, typedArgs[Req](decoded))
+		if err != nil {
+			return err
+		}
+		*reply.(*Resp) = resp
+		return nil
+	}
+	r.register(method, h)
+}
+
+// HandleFunc is like Handle, but takes the handler function with its static
+// type, so that it is invoked, and its arguments decoded, without reflection.
+// It is meant as the registration adapter of generated code.
+// Reply may be ServerStream for server streaming handlers,
+// and Args a receive-only channel for client streaming ones.
+func HandleFunc[Args, Reply any](r Registry, method string, fn func(context.Context, Args, *Reply) error) {
+	h := newTypedHandler[Args, Reply](method)
+	h.invoke = func(ctx context.Context, decoded, reply interface{}) error {
+		return fn(ctx, typedArgs[Args](decoded), reply.(*Reply))
+	}
+	r.register(method, h)
+}
+
+// newTypedHandler returns a handler for arguments of type Args and replies of
+// type Reply, leaving invoke to the caller.
+func newTypedHandler[Args, Reply any](method string) *handler {
+	argType := reflect.TypeOf((*Args)(nil)).Elem()
+	if !isExportedOrBuiltinType(argType) {
+		panic(fmt.Sprintf("birpc: method %s argument type not exported: %s", method, argType))
+	}
+	replyType := reflect.TypeOf((*Reply)(nil))
+	if !isExportedOrBuiltinType(replyType) {
+		panic(fmt.Sprintf("birpc: method %s reply type not exported: %s", method, replyType))
+	}
+	return &handler{
+		argType:   argType,
+		replyType: replyType,
+		upload:    argType.Kind() == reflect.Chan,
+		stream:    replyType == typeOfServerStream,
+		newArgs: func() interface{} {
+			return new(Args)
+		},
+		newReply: func() interface{} {
+			return new(Reply)
+		},
+		args: func(decoded interface{}) interface{} {
+			return typedArgs[Args](decoded)
+		},
+	}
+}
+
+// typedArgs returns the arguments decoded into the result of newArgs,
+// or the channel of items of client streaming calls, as type Args.
+func typedArgs[Args any](decoded interface{}) Args {
+	if p, ok := decoded.(*Args); ok {
+		return *p
+	}
+	return decoded.(Args)
+}
+
+// CallT invokes the named function with req, waits for it to complete,
+// and returns its reply and error status.
+func CallT[Req, Resp any](ctx context.Context, c *Client, method string, req Req) (Resp, error) {
+	var resp Resp
+	err := c.Call(ctx, method, req, &resp)
+	return resp, err
+}
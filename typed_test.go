@@ -0,0 +1,80 @@
+package birpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	type Args struct{ A, B int }
+
+	srv := NewServer()
+	HandleT(srv, "add", func(ctx context.Context, args Args) (int, error) {
+		return args.A + args.B, nil
+	})
+	HandleT(srv, "div", func(ctx context.Context, args *Args) (float64, error) {
+		if args.B == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return float64(args.A) / float64(args.B), nil
+	})
+	HandleFunc(srv, "mul", func(ctx context.Context, args Args, reply *int) error {
+		*reply = args.A * args.B
+		return nil
+	})
+	HandleT(srv, "sum", func(ctx context.Context, nums <-chan int) (int, error) {
+		sum := 0
+		for n := range nums {
+			sum += n
+		}
+		return sum, nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	sum, err := CallT[Args, int](context.Background(), clt, "add", Args{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 3 {
+		t.Fatalf("not expected: %d", sum)
+	}
+
+	var prod int
+	if err = clt.Call(context.Background(), "mul", Args{3, 4}, &prod); err != nil {
+		t.Fatal(err)
+	}
+	if prod != 12 {
+		t.Fatalf("not expected: %d", prod)
+	}
+
+	q, err := CallT[*Args, float64](context.Background(), clt, "div", &Args{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != 1.5 {
+		t.Fatalf("not expected: %f", q)
+	}
+	if _, err = CallT[*Args, float64](context.Background(), clt, "div", &Args{3, 0}); err == nil || err.Error() != "division by zero" {
+		t.Fatalf("expected handler error, got: %v", err)
+	}
+
+	up := clt.Upload(context.Background(), "sum", &sum)
+	for i := 1; i <= 4; i++ {
+		if err = up.Send(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = up.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 10 {
+		t.Fatalf("not expected: %d", sum)
+	}
+}
@@ -0,0 +1,102 @@
+package birpc
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrServerBusy is sent back to the caller when a request arrives while all
+// workers of a WorkerPool are busy and its queue is full.
+var ErrServerBusy = errors.New("birpc: server busy")
+
+// WorkerPool runs request handlers on a bounded number of goroutines.
+// Workers are started on demand, up to the configured limit, and stay
+// around until the pool is closed. Requests that find every worker busy wait
+// in a queue of limited depth; once the queue is full they are rejected with
+// ErrServerBusy. A queue size of zero rejects them at once.
+//
+// A WorkerPool may be shared by many connections, see Server.SetWorkerPool.
+type WorkerPool struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []func()
+	maxQueue int
+	workers  int // maximum number of workers
+	running  int // number of started workers
+	idle     int // number of workers waiting for a task
+	load     int // number of accepted tasks that have not finished yet
+	closed   bool
+}
+
+// NewWorkerPool returns a pool that runs at most workers handlers at a time
+// and queues at most queueSize more.
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers < 1 {
+		panic("birpc: worker pool needs at least one worker")
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &WorkerPool{
+		maxQueue: queueSize,
+		workers:  workers,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Submit schedules task to run on one of the workers.
+// It returns ErrServerBusy if the pool is full and ErrShutdown if it is closed.
+func (p *WorkerPool) Submit(task func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrShutdown
+	}
+	if p.load >= p.workers+p.maxQueue {
+		return ErrServerBusy
+	}
+	p.load++
+	p.queue = append(p.queue, task)
+	switch {
+	case p.idle > 0:
+		p.idle--
+		p.cond.Signal()
+	case p.running < p.workers:
+		p.running++
+		go p.work()
+	}
+	return nil
+}
+
+// Close stops the workers once the queued tasks have run.
+// Tasks submitted after Close are rejected.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+func (p *WorkerPool) work() {
+	p.mu.Lock()
+	for {
+		for len(p.queue) == 0 {
+			if p.closed {
+				p.running--
+				p.mu.Unlock()
+				return
+			}
+			// Submit decrements idle when it wakes us up.
+			p.idle++
+			p.cond.Wait()
+		}
+		task := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+		task()
+		p.mu.Lock()
+		p.load--
+	}
+}
@@ -0,0 +1,69 @@
+package birpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	p := NewWorkerPool(1, 1)
+	defer p.Close()
+
+	release := make(chan struct{})
+	ran := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		i := i
+		if err := p.Submit(func() {
+			<-release
+			ran <- i
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Submit(func() {}); err != ErrServerBusy {
+		t.Fatalf("expected ErrServerBusy, got: %v", err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if got := <-ran; got != i {
+			t.Fatalf("tasks ran out of order: %d", got)
+		}
+	}
+}
+
+func TestServerConnWorkers(t *testing.T) {
+	srv := NewServer()
+	srv.SetConnWorkers(1, 0)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv.Handle("wait", func(ctx context.Context, _ int, _ *int) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	cconn, sconn := net.Pipe()
+	go srv.ServeCodec(NewGobCodec(sconn))
+	clt := NewClient(cconn)
+	go clt.Run()
+	defer clt.Close()
+
+	first := clt.Go("wait", 1, new(int), nil)
+	<-started
+	var rep int
+	err := clt.Call(context.Background(), "wait", 2, &rep)
+	if err == nil || err.Error() != ErrServerBusy.Error() {
+		t.Fatalf("expected server busy, got: %v", err)
+	}
+	close(release)
+	select {
+	case call := <-first.Done:
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first call did not finish")
+	}
+}